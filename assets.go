@@ -0,0 +1,28 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+
+	"soma-mayel-campaign/config"
+)
+
+// embedded holds the templates and the stylesheets and scripts, so a
+// production binary does not depend on files next to it. Images and videos
+// are uploaded or added at runtime and are always served from ./static.
+//
+//go:embed templates static/css static/js
+var embedded embed.FS
+
+// siteFiles returns the templates and static assets: from disk in
+// development, so edits show up on the next request, and from the binary
+// in production
+func siteFiles(cfg *config.Config) (templates, static fs.FS) {
+	if !cfg.IsProduction() {
+		return os.DirFS("templates"), os.DirFS("static")
+	}
+	templates, _ = fs.Sub(embedded, "templates")
+	static, _ = fs.Sub(embedded, "static")
+	return templates, static
+}
@@ -0,0 +1,96 @@
+// Package assets fingerprints static files, so that their URLs change with
+// their content and browsers can cache them for good.
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CacheControl is sent with fingerprinted files, which never change
+const CacheControl = "public, max-age=31536000, immutable"
+
+// hashLength is how many hex digits of the SHA-256 go into a file name
+const hashLength = 10
+
+// Manifest maps static files to fingerprinted names such as
+// css/main.1a2b3c4d5e.css and holds their contents
+type Manifest struct {
+	prefix string
+	names  map[string]string // css/main.css -> css/main.1a2b3c4d5e.css
+	files  map[string][]byte // css/main.1a2b3c4d5e.css -> contents
+}
+
+// Plain returns a manifest without files, whose URLs are the plain paths
+// under prefix. It is used in development, where files change while the
+// server runs.
+func Plain(prefix string) *Manifest {
+	return &Manifest{prefix: prefix, names: map[string]string{}, files: map[string][]byte{}}
+}
+
+// Build reads the files under dirs of fsys and fingerprints them with a
+// hash of their content. prefix is the URL the files are served under, such
+// as /static.
+func Build(fsys fs.FS, prefix string, dirs ...string) (*Manifest, error) {
+	m := Plain(prefix)
+	for _, dir := range dirs {
+		err := fs.WalkDir(fsys, dir, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			body, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(body)
+			ext := path.Ext(name)
+			hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:])[:hashLength] + ext
+			m.names[name] = hashed
+			m.files[hashed] = body
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Len is the number of fingerprinted files
+func (m *Manifest) Len() int {
+	return len(m.names)
+}
+
+// URL returns the address of the static file at name, such as
+// css/main.css. Fingerprinted files get their hashed name; anything else
+// keeps its own.
+func (m *Manifest) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if hashed, ok := m.names[name]; ok {
+		name = hashed
+	}
+	return m.prefix + "/" + name
+}
+
+// Handler returns middleware, mounted at the manifest's prefix, that serves
+// fingerprinted files from memory with an immutable Cache-Control header.
+// Other requests are passed on.
+func (m *Manifest) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return c.Next()
+		}
+		body, ok := m.files[strings.TrimPrefix(c.Path(), m.prefix+"/")]
+		if !ok {
+			return c.Next()
+		}
+		c.Set(fiber.HeaderCacheControl, CacheControl)
+		c.Type(path.Ext(c.Path()))
+		return c.Send(body)
+	}
+}
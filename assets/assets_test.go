@@ -0,0 +1,106 @@
+package assets
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var files = fstest.MapFS{
+	"css/main.css":     {Data: []byte("body{}")},
+	"js/app.js":        {Data: []byte("run()")},
+	"images/logo.png":  {Data: []byte("png")},
+	"css/print/p.css":  {Data: []byte("p{}")},
+	"css/print/p2.css": {Data: []byte("p{}")},
+}
+
+func TestURL(t *testing.T) {
+	m, err := Build(files, "/static", "css", "js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", m.Len())
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"css/main.css", "/static/css/main.7c98040a54.css"},
+		{"/css/main.css", "/static/css/main.7c98040a54.css"},
+		{"js/app.js", "/static/js/app.02fcae88bd.js"},
+		// Same content, same hash, different names
+		{"css/print/p.css", "/static/css/print/p.806db22126.css"},
+		{"css/print/p2.css", "/static/css/print/p2.806db22126.css"},
+		// Not fingerprinted
+		{"images/logo.png", "/static/images/logo.png"},
+		{"css/missing.css", "/static/css/missing.css"},
+	}
+	for _, tt := range tests {
+		if got := m.URL(tt.name); got != tt.want {
+			t.Errorf("URL(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := Plain("/static").URL("css/main.css"); got != "/static/css/main.css" {
+		t.Errorf("Plain URL = %q, want the plain path", got)
+	}
+}
+
+func TestBuildMissingDir(t *testing.T) {
+	if _, err := Build(files, "/static", "fonts"); err == nil {
+		t.Error("Build of a missing directory succeeded")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m, err := Build(files, "/static", "css", "js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Use("/static", m.Handler())
+	app.Use(func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusTeapot).SendString("next")
+	})
+
+	tests := []struct {
+		method      string
+		path        string
+		status      int
+		body        string
+		cached      bool
+		contentType string
+	}{
+		{fiber.MethodGet, m.URL("css/main.css"), fiber.StatusOK, "body{}", true, "text/css"},
+		{fiber.MethodGet, m.URL("js/app.js"), fiber.StatusOK, "run()", true, "text/javascript"},
+		{fiber.MethodHead, m.URL("css/main.css"), fiber.StatusOK, "", true, "text/css"},
+		// The plain name and files that are not fingerprinted are passed on
+		{fiber.MethodGet, "/static/css/main.css", fiber.StatusTeapot, "next", false, ""},
+		{fiber.MethodGet, "/static/images/logo.png", fiber.StatusTeapot, "next", false, ""},
+		{fiber.MethodPost, m.URL("css/main.css"), fiber.StatusTeapot, "next", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status || string(body) != tt.body {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.status, tt.body)
+			}
+			if cached := resp.Header.Get(fiber.HeaderCacheControl) == CacheControl; cached != tt.cached {
+				t.Errorf("Cache-Control = %q", resp.Header.Get(fiber.HeaderCacheControl))
+			}
+			if !strings.HasPrefix(resp.Header.Get(fiber.HeaderContentType), tt.contentType) {
+				t.Errorf("Content-Type = %q, want %s", resp.Header.Get(fiber.HeaderContentType), tt.contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,473 @@
+// Package config loads the server's settings from the environment and a
+// .env file into a typed struct and checks them at startup.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Environments
+const (
+	Development = "development"
+	Production  = "production"
+)
+
+// Default admin credentials, only accepted in development
+const (
+	DefaultAdminUsername = "admin"
+	DefaultAdminPassword = "admin123"
+)
+
+// Config is every setting of the server
+type Config struct {
+	// Env is development or production (ENV, default development)
+	Env string
+
+	Server     Server
+	Logging    Logging
+	Admin      Admin
+	Facebook   Facebook
+	Social     Social
+	Mail       Mail
+	Newsletter Newsletter
+	Forms      Forms
+	Storage    Storage
+	Analytics  Analytics
+}
+
+// Server is how the site is served
+type Server struct {
+	Port            string        // PORT
+	SiteURL         string        // SITE_URL, without a trailing slash
+	AppSecret       string        // APP_SECRET
+	ReadTimeout     time.Duration // READ_TIMEOUT
+	WriteTimeout    time.Duration // WRITE_TIMEOUT
+	IdleTimeout     time.Duration // IDLE_TIMEOUT
+	ShutdownTimeout time.Duration // SHUTDOWN_TIMEOUT
+	BodyLimitMB     int           // BODY_LIMIT_MB
+	ProxyHeader     string        // PROXY_HEADER
+	TrustedProxies  []string      // TRUSTED_PROXIES
+	ReadyChecks     []string      // READY_CHECKS
+	MetricsAddr     string        // METRICS_ADDR
+}
+
+// Logging is the log format and level
+type Logging struct {
+	Format string // LOG_FORMAT
+	Level  string // LOG_LEVEL
+}
+
+// Admin is the login for the admin UI and API
+type Admin struct {
+	Username string // ADMIN_USERNAME
+	Password string // ADMIN_PASSWORD
+}
+
+// Facebook is the Graph API access for the page feed, imports and
+// cross-posting
+type Facebook struct {
+	PageID        string // FACEBOOK_PAGE_ID, or FACEBOOK_PAGE_USERNAME
+	AccessToken   string // FACEBOOK_ACCESS_TOKEN
+	GraphURL      string // FACEBOOK_GRAPH_URL
+	GraphVersion  string // FACEBOOK_GRAPH_VERSION
+	AppID         string // FACEBOOK_APP_ID
+	AppSecret     string // FACEBOOK_APP_SECRET
+	FeedLimit     int    // FACEBOOK_FEED_LIMIT
+	TokenWarnDays int    // FACEBOOK_TOKEN_WARN_DAYS
+}
+
+// Social is the other sources of the social feed
+type Social struct {
+	InstagramUserID string // INSTAGRAM_USER_ID
+	RSSFeeds        []RSSFeed
+	FeedLimit       int // SOCIAL_FEED_LIMIT
+}
+
+// RSSFeed is an entry of RSS_FEEDS, written as name=url
+type RSSFeed struct {
+	Name string
+	URL  string
+}
+
+// Mail is how e-mail is sent
+type Mail struct {
+	Transport    string // MAIL_TRANSPORT: smtp, file, log or empty
+	DropDir      string // MAIL_DROP_DIR
+	SMTPHost     string // SMTP_HOST
+	SMTPPort     string // SMTP_PORT
+	SMTPUser     string // SMTP_USER
+	SMTPPassword string // SMTP_PASS
+	From         string // SMTP_FROM, falling back to SMTP_USER and CONTACT_EMAIL
+	ContactEmail string // CONTACT_EMAIL
+}
+
+// Newsletter is how fast campaigns are sent
+type Newsletter struct {
+	BatchSize     int           // NEWSLETTER_BATCH_SIZE
+	BatchInterval time.Duration // NEWSLETTER_BATCH_INTERVAL
+}
+
+// Forms is the spam protection of the public forms
+type Forms struct {
+	MinSeconds int      // FORM_MIN_SECONDS
+	IPLimit    int      // FORM_IP_LIMIT
+	EmailLimit int      // FORM_EMAIL_LIMIT
+	SpamScore  int      // FORM_SPAM_SCORE
+	Blocklist  []string // FORM_BLOCKLIST
+}
+
+// Retention modes. Retention is off unless chosen, since it deletes data.
+const (
+	RetentionOff    = "off"
+	RetentionDryRun = "dry-run"
+	RetentionOn     = "on"
+)
+
+// Storage is how long personal data in ./data is kept
+type Storage struct {
+	ElectionDate      time.Time         // ELECTION_DATE (YYYY-MM-DD)
+	RetentionMode     string            // RETENTION_MODE: off, dry-run or on
+	RetentionInterval time.Duration     // RETENTION_INTERVAL
+	Retention         map[string]string // RETENTION_<NAME>, keyed by lower-case name
+}
+
+// Analytics is the optional Google Analytics tracking
+type Analytics struct {
+	GATrackingID string // GA_TRACKING_ID
+}
+
+// Load reads .env, if there is one, and the environment, and validates the
+// result. Variables already set in the environment win over .env.
+func Load() (*Config, error) {
+	godotenv.Load()
+	return FromEnv()
+}
+
+// FromEnv reads the settings from the environment and validates them
+func FromEnv() (*Config, error) {
+	return parse(loader{getenv: os.Getenv, environ: os.Environ()})
+}
+
+// Default returns the development settings used when nothing is set
+func Default() *Config {
+	c, _ := parse(loader{getenv: func(string) string { return "" }})
+	return c
+}
+
+func parse(l loader) (*Config, error) {
+	c := &Config{Env: strings.ToLower(l.str("ENV", Development))}
+
+	c.Server = Server{
+		Port:            l.str("PORT", "3000"),
+		SiteURL:         strings.TrimRight(l.str("SITE_URL", ""), "/"),
+		AppSecret:       l.str("APP_SECRET", ""),
+		ReadTimeout:     l.duration("READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:    l.duration("WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:     l.duration("IDLE_TIMEOUT", 120*time.Second),
+		ShutdownTimeout: l.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		BodyLimitMB:     l.int("BODY_LIMIT_MB", 4, 1),
+		ProxyHeader:     l.str("PROXY_HEADER", ""),
+		TrustedProxies:  l.list("TRUSTED_PROXIES"),
+		ReadyChecks:     l.list("READY_CHECKS"),
+		MetricsAddr:     l.str("METRICS_ADDR", ""),
+	}
+	c.Logging = Logging{
+		Format: strings.ToLower(l.str("LOG_FORMAT", "text")),
+		Level:  strings.ToLower(l.str("LOG_LEVEL", "info")),
+	}
+	c.Admin = Admin{
+		Username: l.str("ADMIN_USERNAME", ""),
+		Password: l.str("ADMIN_PASSWORD", ""),
+	}
+	c.Facebook = Facebook{
+		PageID:        l.str("FACEBOOK_PAGE_ID", l.str("FACEBOOK_PAGE_USERNAME", "SomamayelRV")),
+		AccessToken:   l.str("FACEBOOK_ACCESS_TOKEN", ""),
+		GraphURL:      l.str("FACEBOOK_GRAPH_URL", ""),
+		GraphVersion:  l.str("FACEBOOK_GRAPH_VERSION", ""),
+		AppID:         l.str("FACEBOOK_APP_ID", ""),
+		AppSecret:     l.str("FACEBOOK_APP_SECRET", ""),
+		FeedLimit:     l.int("FACEBOOK_FEED_LIMIT", 5, 1),
+		TokenWarnDays: l.int("FACEBOOK_TOKEN_WARN_DAYS", 14, 1),
+	}
+	c.Social = Social{
+		InstagramUserID: l.str("INSTAGRAM_USER_ID", ""),
+		RSSFeeds:        l.feeds("RSS_FEEDS"),
+		FeedLimit:       l.int("SOCIAL_FEED_LIMIT", 10, 1),
+	}
+	c.Mail = Mail{
+		Transport:    strings.ToLower(l.str("MAIL_TRANSPORT", "")),
+		DropDir:      l.str("MAIL_DROP_DIR", "./data/mail"),
+		SMTPHost:     l.str("SMTP_HOST", ""),
+		SMTPPort:     l.str("SMTP_PORT", "587"),
+		SMTPUser:     l.str("SMTP_USER", ""),
+		SMTPPassword: l.str("SMTP_PASS", ""),
+		ContactEmail: l.str("CONTACT_EMAIL", ""),
+	}
+	c.Mail.From = l.str("SMTP_FROM", c.Mail.SMTPUser)
+	if c.Mail.From == "" {
+		c.Mail.From = c.Mail.ContactEmail
+	}
+	c.Newsletter = Newsletter{
+		BatchSize:     l.int("NEWSLETTER_BATCH_SIZE", 50, 1),
+		BatchInterval: l.duration("NEWSLETTER_BATCH_INTERVAL", time.Minute),
+	}
+	c.Forms = Forms{
+		MinSeconds: l.int("FORM_MIN_SECONDS", 3, 0),
+		IPLimit:    l.int("FORM_IP_LIMIT", 5, 1),
+		EmailLimit: l.int("FORM_EMAIL_LIMIT", 3, 1),
+		SpamScore:  l.int("FORM_SPAM_SCORE", 5, 1),
+		Blocklist:  l.list("FORM_BLOCKLIST"),
+	}
+	c.Storage = Storage{
+		ElectionDate:      l.date("ELECTION_DATE"),
+		RetentionMode:     l.str("RETENTION_MODE", RetentionOff),
+		RetentionInterval: l.duration("RETENTION_INTERVAL", 24*time.Hour),
+		Retention:         map[string]string{},
+	}
+	for _, kv := range l.environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if rest := strings.TrimPrefix(name, "RETENTION_"); rest != name && rest != "INTERVAL" && rest != "MODE" && value != "" {
+			c.Storage.Retention[strings.ToLower(rest)] = value
+		}
+	}
+	c.Analytics = Analytics{GATrackingID: l.str("GA_TRACKING_ID", "")}
+
+	l.errs = append(l.errs, c.validate()...)
+	if len(l.errs) > 0 {
+		return c, errors.Join(l.errs...)
+	}
+	if c.Admin.Username == "" || c.Admin.Password == "" {
+		c.Admin.Username, c.Admin.Password = DefaultAdminUsername, DefaultAdminPassword
+	}
+	return c, nil
+}
+
+// validate checks the settings that depend on each other or on ENV
+func (c *Config) validate() []error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Env != Development && c.Env != Production {
+		add("ENV must be %s or %s, not %q", Development, Production, c.Env)
+	}
+	switch c.Logging.Format {
+	case "text", "json":
+	default:
+		add("LOG_FORMAT must be text or json, not %q", c.Logging.Format)
+	}
+	switch c.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		add("LOG_LEVEL must be debug, info, warn or error, not %q", c.Logging.Level)
+	}
+	if c.Server.SiteURL != "" && !strings.HasPrefix(c.Server.SiteURL, "http://") && !strings.HasPrefix(c.Server.SiteURL, "https://") {
+		add("SITE_URL must start with http:// or https://")
+	}
+	if len(c.Server.TrustedProxies) > 0 && c.Server.ProxyHeader == "" {
+		add("TRUSTED_PROXIES is set but PROXY_HEADER is not")
+	}
+	if (c.Admin.Username == "") != (c.Admin.Password == "") {
+		add("ADMIN_USERNAME and ADMIN_PASSWORD must be set together")
+	}
+	switch c.Storage.RetentionMode {
+	case RetentionOff, RetentionDryRun, RetentionOn:
+	default:
+		add("RETENTION_MODE must be %s, %s or %s, not %q", RetentionOff, RetentionDryRun, RetentionOn, c.Storage.RetentionMode)
+	}
+	switch c.Mail.Transport {
+	case "", "file", "log":
+	case "smtp":
+		if c.Mail.SMTPHost == "" {
+			add("MAIL_TRANSPORT is smtp but SMTP_HOST is not set")
+		}
+	default:
+		add("MAIL_TRANSPORT must be smtp, file or log, not %q", c.Mail.Transport)
+	}
+
+	if c.Env == Production {
+		if c.Admin.Password == "" || c.Admin.Password == DefaultAdminPassword {
+			add("ADMIN_PASSWORD must be set to something other than the default in production")
+		}
+		if c.Server.SiteURL == "" {
+			add("SITE_URL is required in production, for links in e-mails and shared posts")
+		}
+		if c.Mail.Transport == "" && c.Mail.SMTPHost == "" {
+			add("SMTP_HOST is required in production, or set MAIL_TRANSPORT to choose file or log delivery")
+		}
+	}
+	return errs
+}
+
+// Warnings are settings that work but are probably not what was meant
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.Admin.Password == DefaultAdminPassword {
+		warnings = append(warnings, "the admin UI uses the default password; set ADMIN_USERNAME and ADMIN_PASSWORD")
+	}
+	if c.Server.ProxyHeader != "" && len(c.Server.TrustedProxies) == 0 {
+		warnings = append(warnings, "PROXY_HEADER is trusted from any client; set TRUSTED_PROXIES")
+	}
+	if c.Mail.ContactEmail == "" {
+		warnings = append(warnings, "CONTACT_EMAIL is not set, so newsletter test sends need an address")
+	}
+	if c.Env == Production && c.Mail.Transport == "log" {
+		warnings = append(warnings, "MAIL_TRANSPORT=log writes whole e-mails, with their recipients, to the log")
+	}
+	if c.Env == Production && c.Storage.RetentionMode != RetentionOn {
+		warnings = append(warnings, "RETENTION_MODE is "+c.Storage.RetentionMode+", so expired personal data is not removed; check GET /api/admin/retention and set it to on")
+	}
+	if c.Env == Production && c.Server.AppSecret == "" {
+		warnings = append(warnings, "APP_SECRET is not set; the generated secret in data/app_secret must be kept with the data")
+	}
+	return warnings
+}
+
+// IsProduction reports whether ENV is production
+func (c *Config) IsProduction() bool {
+	return c.Env == Production
+}
+
+// Summary returns the settings as key-value pairs for logging, with
+// passwords, tokens and secrets replaced by whether they are set
+func (c *Config) Summary() []any {
+	feeds := make([]string, len(c.Social.RSSFeeds))
+	for i, f := range c.Social.RSSFeeds {
+		feeds[i] = f.Name
+	}
+	election := ""
+	if !c.Storage.ElectionDate.IsZero() {
+		election = c.Storage.ElectionDate.Format("2006-01-02")
+	}
+	return []any{
+		"env", c.Env,
+		"server.port", c.Server.Port,
+		"server.site_url", c.Server.SiteURL,
+		"server.app_secret", redact(c.Server.AppSecret),
+		"server.timeouts", fmt.Sprintf("read=%s write=%s idle=%s shutdown=%s", c.Server.ReadTimeout, c.Server.WriteTimeout, c.Server.IdleTimeout, c.Server.ShutdownTimeout),
+		"server.body_limit_mb", c.Server.BodyLimitMB,
+		"server.proxy_header", c.Server.ProxyHeader,
+		"server.trusted_proxies", strings.Join(c.Server.TrustedProxies, ","),
+		"server.ready_checks", strings.Join(c.Server.ReadyChecks, ","),
+		"server.metrics_addr", c.Server.MetricsAddr,
+		"logging", c.Logging.Format + "/" + c.Logging.Level,
+		"admin.username", c.Admin.Username,
+		"admin.password", redact(c.Admin.Password),
+		"facebook.page_id", c.Facebook.PageID,
+		"facebook.access_token", redact(c.Facebook.AccessToken),
+		"facebook.graph_url", c.Facebook.GraphURL,
+		"facebook.app_id", c.Facebook.AppID,
+		"facebook.app_secret", redact(c.Facebook.AppSecret),
+		"social.instagram_user_id", c.Social.InstagramUserID,
+		"social.rss_feeds", strings.Join(feeds, ","),
+		"mail.transport", c.Mail.Transport,
+		"mail.smtp_host", c.Mail.SMTPHost,
+		"mail.smtp_port", c.Mail.SMTPPort,
+		"mail.smtp_user", c.Mail.SMTPUser,
+		"mail.smtp_password", redact(c.Mail.SMTPPassword),
+		"mail.from", c.Mail.From,
+		"mail.contact_email", c.Mail.ContactEmail,
+		"newsletter.batch", fmt.Sprintf("%d every %s", c.Newsletter.BatchSize, c.Newsletter.BatchInterval),
+		"forms", fmt.Sprintf("min_seconds=%d ip_limit=%d email_limit=%d spam_score=%d blocklist=%d", c.Forms.MinSeconds, c.Forms.IPLimit, c.Forms.EmailLimit, c.Forms.SpamScore, len(c.Forms.Blocklist)),
+		"storage.election_date", election,
+		"storage.retention_mode", c.Storage.RetentionMode,
+		"storage.retention_interval", c.Storage.RetentionInterval.String(),
+		"analytics.ga_tracking_id", c.Analytics.GATrackingID,
+	}
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[set]"
+}
+
+// loader reads variables and collects the ones that do not parse
+type loader struct {
+	getenv  func(string) string
+	environ []string
+	errs    []error
+}
+
+func (l *loader) str(name, def string) string {
+	if v := strings.TrimSpace(l.getenv(name)); v != "" {
+		return v
+	}
+	return def
+}
+
+// int reads a whole number of at least min
+func (l *loader) int(name string, def, min int) int {
+	v := l.str(name, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min {
+		l.errs = append(l.errs, fmt.Errorf("%s must be a whole number of at least %d, not %q", name, min, v))
+		return def
+	}
+	return n
+}
+
+// duration reads a positive duration such as "30s" or "24h"
+func (l *loader) duration(name string, def time.Duration) time.Duration {
+	v := l.str(name, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		l.errs = append(l.errs, fmt.Errorf("%s must be a duration such as 30s or 24h, not %q", name, v))
+		return def
+	}
+	return d
+}
+
+// date reads a YYYY-MM-DD date in local time
+func (l *loader) date(name string) time.Time {
+	v := l.str(name, "")
+	if v == "" {
+		return time.Time{}
+	}
+	d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s must be a date like 2026-11-17, not %q", name, v))
+	}
+	return d
+}
+
+// list reads a comma-separated list, dropping empty entries
+func (l *loader) list(name string) []string {
+	var items []string
+	for _, item := range strings.Split(l.getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// feeds reads a comma-separated list of name=url entries
+func (l *loader) feeds(name string) []RSSFeed {
+	var feeds []RSSFeed
+	for _, entry := range l.list(name) {
+		feedName, url, ok := strings.Cut(entry, "=")
+		feedName, url = strings.TrimSpace(feedName), strings.TrimSpace(url)
+		if !ok || feedName == "" || url == "" {
+			l.errs = append(l.errs, fmt.Errorf("%s entry %q must be written as name=url", name, entry))
+			continue
+		}
+		feeds = append(feeds, RSSFeed{Name: feedName, URL: url})
+	}
+	return feeds
+}
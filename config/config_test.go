@@ -0,0 +1,169 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseEnv parses the settings from env alone
+func parseEnv(env map[string]string) (*Config, error) {
+	var environ []string
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+	return parse(loader{getenv: func(name string) string { return env[name] }, environ: environ})
+}
+
+// production is the least a production configuration needs
+func production(extra map[string]string) map[string]string {
+	env := map[string]string{
+		"ENV":            Production,
+		"ADMIN_USERNAME": "editor",
+		"ADMIN_PASSWORD": "correct horse",
+		"SITE_URL":       "https://example.org",
+		"SMTP_HOST":      "smtp.example.org",
+	}
+	for k, v := range extra {
+		env[k] = v
+	}
+	return env
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string // part of the error; empty if the settings are valid
+	}{
+		{"defaults", nil, ""},
+		{"production", production(nil), ""},
+		{"unknown environment", map[string]string{"ENV": "staging"}, `ENV must be development or production, not "staging"`},
+		{"environment is case insensitive", map[string]string{"ENV": "Development"}, ""},
+		{"bad number", map[string]string{"FACEBOOK_FEED_LIMIT": "many"}, `FACEBOOK_FEED_LIMIT must be a whole number of at least 1, not "many"`},
+		{"number below minimum", map[string]string{"FORM_IP_LIMIT": "0"}, "FORM_IP_LIMIT must be a whole number of at least 1"},
+		{"zero allowed where minimum is zero", map[string]string{"FORM_MIN_SECONDS": "0"}, ""},
+		{"bad duration", map[string]string{"READ_TIMEOUT": "30"}, "READ_TIMEOUT must be a duration"},
+		{"negative duration", map[string]string{"RETENTION_INTERVAL": "-1h"}, "RETENTION_INTERVAL must be a duration"},
+		{"bad date", map[string]string{"ELECTION_DATE": "17/11/2026"}, "ELECTION_DATE must be a date"},
+		{"bad feed", map[string]string{"RSS_FEEDS": "https://example.org/feed"}, "must be written as name=url"},
+		{"bad log format", map[string]string{"LOG_FORMAT": "xml"}, "LOG_FORMAT must be text or json"},
+		{"bad log level", map[string]string{"LOG_LEVEL": "trace"}, "LOG_LEVEL must be"},
+		{"site url without scheme", map[string]string{"SITE_URL": "example.org"}, "SITE_URL must start with http:// or https://"},
+		{"trusted proxies without header", map[string]string{"TRUSTED_PROXIES": "10.0.0.1"}, "TRUSTED_PROXIES is set but PROXY_HEADER is not"},
+		{"username without password", map[string]string{"ADMIN_USERNAME": "editor"}, "must be set together"},
+		{"bad retention mode", map[string]string{"RETENTION_MODE": "yes"}, `RETENTION_MODE must be off, dry-run or on, not "yes"`},
+		{"smtp without host", map[string]string{"MAIL_TRANSPORT": "smtp"}, "MAIL_TRANSPORT is smtp but SMTP_HOST is not set"},
+		{"unknown transport", map[string]string{"MAIL_TRANSPORT": "pigeon"}, "MAIL_TRANSPORT must be smtp, file or log"},
+		{"production with default password", production(map[string]string{"ADMIN_PASSWORD": DefaultAdminPassword}), "ADMIN_PASSWORD must be set to something other than the default"},
+		{"production without credentials", production(map[string]string{"ADMIN_USERNAME": "", "ADMIN_PASSWORD": ""}), "ADMIN_PASSWORD must be set"},
+		{"production without site url", production(map[string]string{"SITE_URL": ""}), "SITE_URL is required in production"},
+		{"production without mail", production(map[string]string{"SMTP_HOST": ""}), "SMTP_HOST is required in production"},
+		{"production with file mail", production(map[string]string{"SMTP_HOST": "", "MAIL_TRANSPORT": "file"}), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseEnv(tt.env)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("no error, want one containing %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCollectsAllErrors(t *testing.T) {
+	_, err := parseEnv(map[string]string{"ENV": "staging", "READ_TIMEOUT": "x", "LOG_LEVEL": "loud"})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 3 {
+		t.Errorf("got %d errors, want 3: %v", n, err)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	c, err := parseEnv(map[string]string{
+		"SITE_URL":                  "https://example.org/",
+		"FACEBOOK_PAGE_USERNAME":    "somepage",
+		"MAIL_TRANSPORT":            "FILE",
+		"SMTP_USER":                 "user@example.org",
+		"TRUSTED_PROXIES":           " 10.0.0.1, ,10.0.0.2 ",
+		"PROXY_HEADER":              "X-Forwarded-For",
+		"RSS_FEEDS":                 "Blog = https://example.org/feed, News=https://example.org/news",
+		"NEWSLETTER_BATCH_INTERVAL": "90s",
+		"ELECTION_DATE":             "2026-11-17",
+		"RETENTION_MODE":            "dry-run",
+		"RETENTION_INTERVAL":        "1h",
+		"RETENTION_SUBSCRIBERS":     "730d",
+		"RETENTION_QUARANTINE":      "30d",
+		"RETENTION_EMPTY":           "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"site url without trailing slash", c.Server.SiteURL, "https://example.org"},
+		{"page id from username", c.Facebook.PageID, "somepage"},
+		{"transport lower-cased", c.Mail.Transport, "file"},
+		{"from defaults to smtp user", c.Mail.From, "user@example.org"},
+		{"trusted proxies", c.Server.TrustedProxies, []string{"10.0.0.1", "10.0.0.2"}},
+		{"feeds", c.Social.RSSFeeds, []RSSFeed{{Name: "Blog", URL: "https://example.org/feed"}, {Name: "News", URL: "https://example.org/news"}}},
+		{"batch interval", c.Newsletter.BatchInterval, 90 * time.Second},
+		{"election date", c.Storage.ElectionDate.Format("2006-01-02"), "2026-11-17"},
+		{"retention mode", c.Storage.RetentionMode, RetentionDryRun},
+		{"retention interval", c.Storage.RetentionInterval, time.Hour},
+		{"retention rules", c.Storage.Retention, map[string]string{"subscribers": "730d", "quarantine": "30d"}},
+		{"default admin in development", c.Admin.Username + ":" + c.Admin.Password, DefaultAdminUsername + ":" + DefaultAdminPassword},
+		{"default feed limit", c.Facebook.FeedLimit, 5},
+	}
+	for _, check := range checks {
+		if !reflect.DeepEqual(check.got, check.want) {
+			t.Errorf("%s = %#v, want %#v", check.name, check.got, check.want)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string // part of a warning; empty for no warning about it
+	}{
+		{"default password", nil, "default password"},
+		{"untrusted proxy header", map[string]string{"PROXY_HEADER": "X-Forwarded-For"}, "PROXY_HEADER is trusted from any client"},
+		{"log transport in production", production(map[string]string{"MAIL_TRANSPORT": "log"}), "MAIL_TRANSPORT=log"},
+		{"retention off in production", production(nil), "RETENTION_MODE is off"},
+		{"retention dry run in production", production(map[string]string{"RETENTION_MODE": "dry-run"}), "RETENTION_MODE is dry-run"},
+		{"no app secret in production", production(nil), "APP_SECRET is not set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseEnv(tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			warnings := strings.Join(c.Warnings(), "\n")
+			if !strings.Contains(warnings, tt.want) {
+				t.Errorf("warnings %q do not contain %q", warnings, tt.want)
+			}
+		})
+	}
+
+	c, err := parseEnv(production(map[string]string{"RETENTION_MODE": RetentionOn, "APP_SECRET": "s", "CONTACT_EMAIL": "a@example.org"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := c.Warnings(); len(w) != 0 {
+		t.Errorf("complete production settings give warnings: %q", w)
+	}
+}
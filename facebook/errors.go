@@ -0,0 +1,99 @@
+package facebook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Graph API error codes the site reacts to. See
+// https://developers.facebook.com/docs/graph-api/guides/error-handling
+const (
+	CodeAPIUnknown          = 1
+	CodeAPIService          = 2
+	CodeAPITooManyCalls     = 4
+	CodeAPIUserTooManyCalls = 17
+	CodePermissionDenied    = 10
+	CodeAccessTokenInvalid  = 190
+	CodeAppRateLimit        = 32
+	CodePageRateLimit       = 613
+	CodeBusinessRateLimit   = 80001
+)
+
+// Error is an error returned by the Graph API
+type Error struct {
+	Status    int    `json:"-"`
+	Message   string `json:"message"`
+	Type      string `json:"type"`
+	Code      int    `json:"code"`
+	Subcode   int    `json:"error_subcode"`
+	Transient bool   `json:"is_transient"`
+	FBTraceID string `json:"fbtrace_id"`
+}
+
+func (e *Error) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("facebook api status %d: (#%d) %s", e.Status, e.Code, e.Message)
+	}
+	return fmt.Sprintf("facebook api status %d", e.Status)
+}
+
+// TokenExpired reports whether the access token is expired or invalid
+func (e *Error) TokenExpired() bool {
+	return e.Code == CodeAccessTokenInvalid
+}
+
+// RateLimited reports whether the request was throttled
+func (e *Error) RateLimited() bool {
+	switch e.Code {
+	case CodeAPITooManyCalls, CodeAPIUserTooManyCalls, CodeAppRateLimit, CodePageRateLimit, CodeBusinessRateLimit:
+		return true
+	}
+	return e.Status == 429
+}
+
+// Temporary reports whether repeating the request may succeed: it was
+// throttled, or Graph failed on its side. Errors about the token,
+// permissions or the request itself are not temporary.
+func (e *Error) Temporary() bool {
+	switch {
+	case e.Transient, e.RateLimited(), e.Status >= 500:
+		return true
+	}
+	return e.Code == CodeAPIUnknown || e.Code == CodeAPIService
+}
+
+// IsTemporary reports whether err may go away on its own: a temporary Graph
+// error, or a failure to reach Graph at all, such as a timeout
+func IsTemporary(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Temporary()
+	}
+	return !errors.Is(err, ErrNoAccessToken) && !errors.Is(err, ErrNoAppCredentials)
+}
+
+// IsTokenExpired reports whether err is a Graph error for an expired or
+// invalid access token
+func IsTokenExpired(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.TokenExpired()
+}
+
+// IsRateLimited reports whether err is a Graph rate limit error
+func IsRateLimited(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.RateLimited()
+}
+
+// parseError builds an Error from a non-2xx response body
+func parseError(status int, body []byte) error {
+	var envelope struct {
+		Error *Error `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error == nil {
+		return &Error{Status: status}
+	}
+	envelope.Error.Status = status
+	return envelope.Error
+}
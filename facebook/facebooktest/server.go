@@ -0,0 +1,372 @@
+// Package facebooktest provides a fake Graph API server for tests.
+package facebooktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/facebook"
+)
+
+// Server is an in-memory Graph API serving the posts of any page and the
+// media of any Instagram account. It pages results with "paging.next" links
+// that point back at itself.
+type Server struct {
+	*httptest.Server
+
+	// Token is the access token requests must carry; empty accepts any
+	Token string
+	// AppID and AppSecret are the credentials token exchanges must use
+	AppID     string
+	AppSecret string
+	// PageID is the page /me/accounts lists for every valid user token
+	PageID string
+	// PageUsername is the username listed with PageID, if any
+	PageUsername string
+
+	mu        sync.Mutex
+	posts     []facebook.Post
+	media     []facebook.Media
+	published []facebook.Publication
+	pageSize  int
+	err       *facebook.Error
+	lostReply *facebook.Error
+	requests  int
+	tokens    map[string]facebook.TokenInfo
+}
+
+// NewServer starts a fake Graph API serving posts, newest first
+func NewServer(posts ...facebook.Post) *Server {
+	s := &Server{posts: posts, pageSize: 25, tokens: map[string]facebook.TokenInfo{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Client returns a GraphClient pointed at the fake server
+func (s *Server) Client() *facebook.GraphClient {
+	token := s.Token
+	if token == "" {
+		token = "test-token"
+	}
+	c := facebook.NewClient(s.URL, facebook.DefaultVersion, token)
+	c.AppID = s.AppID
+	c.AppSecret = s.AppSecret
+	return c
+}
+
+// SetTokenInfo sets what /debug_token reports for token
+func (s *Server) SetTokenInfo(token string, info facebook.TokenInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = info
+}
+
+// SetPosts replaces the posts served
+func (s *Server) SetPosts(posts ...facebook.Post) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posts = posts
+}
+
+// SetMedia replaces the Instagram media served
+func (s *Server) SetMedia(media ...facebook.Media) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.media = media
+}
+
+// Published returns the posts published through the fake so far
+func (s *Server) Published() []facebook.Publication {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]facebook.Publication(nil), s.published...)
+}
+
+// SetPageSize limits how many posts each page holds
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// FailWith makes every request fail with the given Graph error until it is
+// called again with nil
+func (s *Server) FailWith(err *facebook.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// LoseNextReply makes the next publish go through but answer with err, as
+// when Facebook creates the post and the response is lost
+func (s *Server) LoseNextReply(err *facebook.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lostReply = err
+}
+
+// Requests returns the number of requests served so far
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// ExpiredToken is the error Graph returns for an expired access token
+func ExpiredToken() *facebook.Error {
+	return &facebook.Error{
+		Status:  http.StatusBadRequest,
+		Message: "Error validating access token: Session has expired",
+		Type:    "OAuthException",
+		Code:    facebook.CodeAccessTokenInvalid,
+		Subcode: 463,
+	}
+}
+
+// RateLimited is the error Graph returns when the page is throttled
+func RateLimited() *facebook.Error {
+	return &facebook.Error{
+		Status:  http.StatusForbidden,
+		Message: "Page request limit reached",
+		Type:    "OAuthException",
+		Code:    facebook.CodeAppRateLimit,
+	}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.err != nil {
+		writeError(w, s.err)
+		return
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/debug_token"):
+		s.debugToken(w, r)
+		return
+	case strings.HasSuffix(r.URL.Path, "/oauth/access_token"):
+		s.exchangeToken(w, r)
+		return
+	case strings.HasSuffix(r.URL.Path, "/me/accounts"):
+		s.accounts(w, r)
+		return
+	}
+
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, &facebook.Error{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid OAuth access token.",
+			Type:    "OAuthException",
+			Code:    facebook.CodeAccessTokenInvalid,
+		})
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.publish(w, r)
+		return
+	}
+
+	var items []map[string]string
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/posts"):
+		for _, p := range s.posts {
+			items = append(items, map[string]string{
+				"id":            p.ID,
+				"message":       p.Message,
+				"created_time":  p.CreatedTime.Format(time.RFC3339),
+				"permalink_url": p.PermalinkURL,
+				"full_picture":  p.FullPicture,
+			})
+		}
+	case strings.HasSuffix(r.URL.Path, "/media"):
+		for _, m := range s.media {
+			items = append(items, map[string]string{
+				"id":            m.ID,
+				"caption":       m.Caption,
+				"media_type":    m.MediaType,
+				"media_url":     m.MediaURL,
+				"thumbnail_url": m.ThumbnailURL,
+				"permalink":     m.Permalink,
+				"timestamp":     m.Timestamp.Format("2006-01-02T15:04:05-0700"),
+			})
+		}
+	default:
+		writeError(w, &facebook.Error{Status: http.StatusNotFound, Message: "Unknown path", Code: 803})
+		return
+	}
+	s.writePage(w, r, items)
+}
+
+// publish answers POST /{page}/feed and /{page}/photos and adds the post
+// to the page's posts
+func (s *Server) publish(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 {
+		writeError(w, &facebook.Error{Status: http.StatusNotFound, Message: "Unknown path", Code: 803})
+		return
+	}
+	pageID, edge := parts[len(parts)-2], parts[len(parts)-1]
+
+	var p facebook.Publication
+	switch edge {
+	case "feed":
+		p = facebook.Publication{Message: r.PostForm.Get("message"), Link: r.PostForm.Get("link")}
+	case "photos":
+		p = facebook.Publication{Message: r.PostForm.Get("caption"), ImageURL: r.PostForm.Get("url")}
+	default:
+		writeError(w, &facebook.Error{Status: http.StatusNotFound, Message: "Unknown path", Code: 803})
+		return
+	}
+	s.published = append(s.published, p)
+
+	id := fmt.Sprintf("%s_%d", pageID, len(s.published))
+	s.posts = append([]facebook.Post{{ID: id, Message: p.Message, CreatedTime: time.Now().UTC()}}, s.posts...)
+	if s.lostReply != nil {
+		writeError(w, s.lostReply)
+		s.lostReply = nil
+		return
+	}
+	if edge == "photos" {
+		json.NewEncoder(w).Encode(map[string]string{"id": strconv.Itoa(len(s.published)), "post_id": id})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// writePage writes one page of items with a "paging.next" link when more
+// remain
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, items []map[string]string) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > s.pageSize {
+		limit = s.pageSize
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset > len(items) {
+		offset = len(items)
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	resp := map[string]interface{}{"data": append([]map[string]string{}, items[offset:end]...)}
+	if end < len(items) {
+		q := r.URL.Query()
+		q.Set("offset", strconv.Itoa(end))
+		resp["paging"] = map[string]string{
+			"next": fmt.Sprintf("%s%s?%s", s.URL, r.URL.Path, q.Encode()),
+		}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// debugToken answers /debug_token from the infos set with SetTokenInfo
+func (s *Server) debugToken(w http.ResponseWriter, r *http.Request) {
+	input := r.URL.Query().Get("input_token")
+	data := map[string]interface{}{"is_valid": false}
+	if info, ok := s.tokens[input]; ok {
+		data = map[string]interface{}{
+			"app_id":      info.AppID,
+			"application": info.Application,
+			"type":        info.Type,
+			"user_id":     info.UserID,
+			"is_valid":    info.IsValid,
+			"expires_at":  unixOrZero(info.ExpiresAt),
+			"scopes":      info.Scopes,
+		}
+		if !info.DataAccessExpiresAt.IsZero() {
+			data["data_access_expires_at"] = info.DataAccessExpiresAt.Unix()
+		}
+	} else {
+		data["error"] = map[string]interface{}{"code": facebook.CodeAccessTokenInvalid, "message": "Invalid OAuth access token."}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// exchangeToken answers /oauth/access_token with a 60 day token named
+// "long-lived-" plus the short-lived token, and registers it as valid
+func (s *Server) exchangeToken(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("grant_type") != "fb_exchange_token" || q.Get("fb_exchange_token") == "" ||
+		(s.AppID != "" && q.Get("client_id") != s.AppID) ||
+		(s.AppSecret != "" && q.Get("client_secret") != s.AppSecret) {
+		writeError(w, &facebook.Error{
+			Status:  http.StatusBadRequest,
+			Message: "Error validating client secret.",
+			Type:    "OAuthException",
+			Code:    1,
+		})
+		return
+	}
+
+	const expiresIn = 60 * 24 * 60 * 60
+	token := "long-lived-" + q.Get("fb_exchange_token")
+	s.tokens[token] = facebook.TokenInfo{
+		AppID:     s.AppID,
+		Type:      "USER",
+		IsValid:   true,
+		ExpiresAt: time.Now().Add(expiresIn * time.Second),
+		Scopes:    []string{"pages_read_engagement", "pages_show_list"},
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": token,
+		"token_type":   "bearer",
+		"expires_in":   expiresIn,
+	})
+}
+
+// accounts answers /me/accounts for a user token registered with
+// SetTokenInfo or by an exchange. It lists PageID with a never-expiring page
+// token named "page-" plus the user token, and registers that as valid.
+func (s *Server) accounts(w http.ResponseWriter, r *http.Request) {
+	userToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if info, ok := s.tokens[userToken]; !ok || !info.IsValid || info.Type != "USER" {
+		writeError(w, &facebook.Error{
+			Status:  http.StatusBadRequest,
+			Message: "Invalid OAuth access token.",
+			Type:    "OAuthException",
+			Code:    facebook.CodeAccessTokenInvalid,
+		})
+		return
+	}
+
+	items := []map[string]string{}
+	if s.PageID != "" {
+		token := "page-" + userToken
+		s.tokens[token] = facebook.TokenInfo{
+			AppID:   s.AppID,
+			Type:    "PAGE",
+			IsValid: true,
+			Scopes:  []string{"pages_read_engagement", "pages_manage_posts"},
+		}
+		page := map[string]string{"id": s.PageID, "name": "Test page", "access_token": token}
+		if s.PageUsername != "" {
+			page["username"] = s.PageUsername
+		}
+		items = append(items, page)
+	}
+	s.writePage(w, r, items)
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func writeError(w http.ResponseWriter, e *facebook.Error) {
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(map[string]*facebook.Error{"error": e})
+}
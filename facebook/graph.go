@@ -0,0 +1,238 @@
+// Package facebook is a small client for the parts of the Facebook Graph API
+// the site uses.
+package facebook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the public Graph API endpoint
+	DefaultBaseURL = "https://graph.facebook.com"
+	// DefaultVersion is the Graph API version used when none is configured
+	DefaultVersion = "v18.0"
+
+	postFields = "message,created_time,permalink_url,full_picture"
+	// maxPages bounds how many "next" links are followed in one call
+	maxPages = 10
+)
+
+// ErrNoAccessToken is returned when the client has no access token
+var ErrNoAccessToken = errors.New("missing_access_token")
+
+// Post is a post on a Facebook page
+type Post struct {
+	ID           string    `json:"id"`
+	Message      string    `json:"message,omitempty"`
+	CreatedTime  time.Time `json:"created_time"`
+	PermalinkURL string    `json:"permalink_url,omitempty"`
+	FullPicture  string    `json:"full_picture,omitempty"`
+}
+
+// Client fetches content from the Graph API
+type Client interface {
+	// PagePosts returns up to limit of the page's newest posts, following
+	// the paging cursor as needed
+	PagePosts(ctx context.Context, pageID string, limit int) ([]Post, error)
+}
+
+// GraphClient is the HTTP implementation of Client
+type GraphClient struct {
+	BaseURL     string
+	Version     string
+	AccessToken string
+	HTTP        *http.Client
+
+	// AppID and AppSecret are needed to exchange and inspect tokens
+	AppID     string
+	AppSecret string
+}
+
+// NewClient creates a client for the Graph API at baseURL
+func NewClient(baseURL, version, accessToken string) *GraphClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if version == "" {
+		version = DefaultVersion
+	}
+	return &GraphClient{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		Version:     version,
+		AccessToken: accessToken,
+		HTTP:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type postsPage struct {
+	Data []struct {
+		ID           string `json:"id"`
+		Message      string `json:"message"`
+		CreatedTime  string `json:"created_time"`
+		PermalinkURL string `json:"permalink_url"`
+		FullPicture  string `json:"full_picture"`
+	} `json:"data"`
+	Paging struct {
+		Next string `json:"next"`
+	} `json:"paging"`
+}
+
+// PagePosts implements Client
+func (c *GraphClient) PagePosts(ctx context.Context, pageID string, limit int) ([]Post, error) {
+	if c.AccessToken == "" {
+		return nil, ErrNoAccessToken
+	}
+	if limit <= 0 {
+		limit = 5
+	}
+
+	q := url.Values{}
+	q.Set("fields", postFields)
+	q.Set("limit", strconv.Itoa(limit))
+	next := c.endpoint(url.PathEscape(pageID)+"/posts", q)
+
+	posts := make([]Post, 0, limit)
+	for page := 0; next != "" && len(posts) < limit && page < maxPages; page++ {
+		var p postsPage
+		if err := c.get(ctx, next, &p); err != nil {
+			return nil, err
+		}
+		for _, d := range p.Data {
+			posts = append(posts, Post{
+				ID:           d.ID,
+				Message:      d.Message,
+				CreatedTime:  parseTime(d.CreatedTime),
+				PermalinkURL: d.PermalinkURL,
+				FullPicture:  d.FullPicture,
+			})
+		}
+		next = p.Paging.Next
+	}
+
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+	return posts, nil
+}
+
+// parseTime parses a Graph timestamp, which comes as RFC 3339 or with a
+// "+0000" style offset. It returns the zero time if neither matches.
+func parseTime(s string) time.Time {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	t, _ := time.Parse("2006-01-02T15:04:05-0700", s)
+	return t
+}
+
+// Ping checks that the Graph API answers. Any HTTP response counts, since
+// requests without a valid token are answered with an error.
+func (c *GraphClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("me", nil), nil)
+	if err != nil {
+		return err
+	}
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// get fetches rawURL with the client's access token and decodes the JSON
+// body into v
+func (c *GraphClient) get(ctx context.Context, rawURL string, v interface{}) error {
+	return c.getWithToken(ctx, rawURL, c.AccessToken, v)
+}
+
+// getWithToken is get with an explicit token
+func (c *GraphClient) getWithToken(ctx context.Context, rawURL, token string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return redactURL(err)
+	}
+	return c.do(req, token, v)
+}
+
+// post sends form to rawURL with the client's access token and decodes the
+// JSON response into v
+func (c *GraphClient) post(ctx context.Context, rawURL string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return redactURL(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, c.AccessToken, v)
+}
+
+// do sends req and decodes the JSON response into v, turning error
+// responses into *Error. The client's token is sent in the Authorization
+// header, but query strings still carry secrets ("next" links hold the
+// token, token exchanges the app secret), so they are dropped from the
+// URL in transport errors, which get logged and shown in the admin UI.
+func (c *GraphClient) do(req *http.Request, token string, v interface{}) error {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return redactURL(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 4<<20))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return parseError(res.StatusCode, body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// redactURL removes the query string and any user info from the URL held
+// by a *url.Error
+func redactURL(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		urlErr.URL = "[redacted]"
+		return err
+	}
+	u.RawQuery = ""
+	u.User = nil
+	urlErr.URL = u.String()
+	return err
+}
+
+// endpoint returns the URL of a Graph path with query q
+func (c *GraphClient) endpoint(path string, q url.Values) string {
+	u := fmt.Sprintf("%s/%s/%s", c.BaseURL, c.Version, path)
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	return u
+}
@@ -0,0 +1,140 @@
+package facebook_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/facebook/facebooktest"
+)
+
+func posts(n int) []facebook.Post {
+	newest := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	list := make([]facebook.Post, n)
+	for i := range list {
+		list[i] = facebook.Post{ID: fmt.Sprintf("1234_%d", n-i), Message: fmt.Sprintf("Post %d", n-i), CreatedTime: newest.Add(-time.Duration(i) * time.Hour)}
+	}
+	return list
+}
+
+func TestPagePosts(t *testing.T) {
+	tests := []struct {
+		name         string
+		posts        int
+		pageSize     int
+		limit        int
+		want         int
+		wantRequests int
+	}{
+		{"one page", 3, 25, 5, 3, 1},
+		{"several pages", 7, 2, 5, 5, 3},
+		{"last page", 3, 2, 10, 3, 2},
+		{"no posts", 0, 25, 5, 0, 1},
+		{"default limit", 10, 25, 0, 5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := facebooktest.NewServer(posts(tt.posts)...)
+			defer srv.Close()
+			srv.SetPageSize(tt.pageSize)
+
+			got, err := srv.Client().PagePosts(context.Background(), "1234", tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want || srv.Requests() != tt.wantRequests {
+				t.Fatalf("got %d posts in %d requests, want %d in %d", len(got), srv.Requests(), tt.want, tt.wantRequests)
+			}
+			for i, p := range got {
+				if want := posts(tt.posts)[i]; p.ID != want.ID || !p.CreatedTime.Equal(want.CreatedTime) {
+					t.Errorf("post %d is %s at %v, want %s at %v", i, p.ID, p.CreatedTime, want.ID, want.CreatedTime)
+				}
+			}
+		})
+	}
+}
+
+func TestGraphErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		fail        *facebook.Error
+		token       string
+		wantExpired bool
+		wantLimited bool
+		wantRetry   bool
+	}{
+		{name: "expired token", fail: facebooktest.ExpiredToken(), wantExpired: true},
+		{name: "rate limited", fail: facebooktest.RateLimited(), wantLimited: true, wantRetry: true},
+		{name: "too many requests", fail: &facebook.Error{Status: 429, Message: "Slow down"}, wantLimited: true, wantRetry: true},
+		{name: "server error", fail: &facebook.Error{Status: 500, Message: "Unknown error", Code: 1}, wantRetry: true},
+		{name: "transient", fail: &facebook.Error{Status: 400, Message: "Try again", Code: 368, Transient: true}, wantRetry: true},
+		{name: "permission denied", fail: &facebook.Error{Status: 403, Message: "Permissions error", Code: facebook.CodePermissionDenied}},
+		{name: "invalid parameter", fail: &facebook.Error{Status: 400, Message: "Invalid parameter", Code: 100}},
+		{name: "wrong token", token: "someone-else", wantExpired: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := facebooktest.NewServer(posts(3)...)
+			defer srv.Close()
+			srv.Token = "page-token"
+			srv.FailWith(tt.fail)
+
+			client := srv.Client()
+			if tt.token != "" {
+				client.AccessToken = tt.token
+			}
+			_, err := client.PagePosts(context.Background(), "1234", 5)
+			var graphErr *facebook.Error
+			if !errors.As(err, &graphErr) {
+				t.Fatalf("error = %v, want a *facebook.Error", err)
+			}
+			if facebook.IsTokenExpired(err) != tt.wantExpired || facebook.IsRateLimited(err) != tt.wantLimited {
+				t.Errorf("expired %v, rate limited %v, want %v, %v", facebook.IsTokenExpired(err), facebook.IsRateLimited(err), tt.wantExpired, tt.wantLimited)
+			}
+			if facebook.IsTemporary(err) != tt.wantRetry {
+				t.Errorf("temporary %v, want %v", facebook.IsTemporary(err), tt.wantRetry)
+			}
+		})
+	}
+
+	if _, err := facebook.NewClient("http://127.0.0.1:1", "", "").PagePosts(context.Background(), "1234", 5); err != facebook.ErrNoAccessToken {
+		t.Errorf("without a token: error = %v, want %v", err, facebook.ErrNoAccessToken)
+	}
+	if facebook.IsTemporary(facebook.ErrNoAccessToken) {
+		t.Error("a missing token counts as temporary")
+	}
+}
+
+func TestTransportErrorsHideSecrets(t *testing.T) {
+	srv := facebooktest.NewServer()
+	srv.Close()
+
+	client := facebook.NewClient(srv.URL, "", "page-token")
+	client.AppID = "app-id"
+	client.AppSecret = "app-secret"
+
+	_, err := client.ExchangeToken(context.Background(), "short-lived-token")
+	if err == nil {
+		t.Fatal("ExchangeToken against a closed server succeeded")
+	}
+	for _, secret := range []string{"app-secret", "short-lived-token"} {
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("ExchangeToken error %q contains %q", err, secret)
+		}
+	}
+	if !strings.Contains(err.Error(), "oauth/access_token") {
+		t.Errorf("ExchangeToken error %q no longer names the endpoint", err)
+	}
+
+	if !facebook.IsTemporary(err) {
+		t.Errorf("unreachable Graph: %v is not temporary", err)
+	}
+
+	if _, err := client.DebugToken(context.Background(), "inspected-token"); err == nil || strings.Contains(err.Error(), "inspected-token") {
+		t.Errorf("DebugToken error = %v, want one without the inspected token", err)
+	}
+}
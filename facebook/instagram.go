@@ -0,0 +1,83 @@
+package facebook
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const mediaFields = "caption,media_type,media_url,thumbnail_url,permalink,timestamp"
+
+// Media is a post on an Instagram professional account
+type Media struct {
+	ID           string    `json:"id"`
+	Caption      string    `json:"caption,omitempty"`
+	MediaType    string    `json:"media_type,omitempty"`
+	MediaURL     string    `json:"media_url,omitempty"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	Permalink    string    `json:"permalink,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// InstagramClient fetches media from the Instagram Graph API
+type InstagramClient interface {
+	// UserMedia returns up to limit of the account's newest media
+	UserMedia(ctx context.Context, userID string, limit int) ([]Media, error)
+}
+
+type mediaPage struct {
+	Data []struct {
+		ID           string `json:"id"`
+		Caption      string `json:"caption"`
+		MediaType    string `json:"media_type"`
+		MediaURL     string `json:"media_url"`
+		ThumbnailURL string `json:"thumbnail_url"`
+		Permalink    string `json:"permalink"`
+		Timestamp    string `json:"timestamp"`
+	} `json:"data"`
+	Paging struct {
+		Next string `json:"next"`
+	} `json:"paging"`
+}
+
+// UserMedia implements InstagramClient. The Instagram account must be linked
+// to the Facebook page whose token the client uses.
+func (c *GraphClient) UserMedia(ctx context.Context, userID string, limit int) ([]Media, error) {
+	if c.AccessToken == "" {
+		return nil, ErrNoAccessToken
+	}
+	if limit <= 0 {
+		limit = 5
+	}
+
+	q := url.Values{}
+	q.Set("fields", mediaFields)
+	q.Set("limit", strconv.Itoa(limit))
+	next := c.endpoint(url.PathEscape(userID)+"/media", q)
+
+	media := make([]Media, 0, limit)
+	for page := 0; next != "" && len(media) < limit && page < maxPages; page++ {
+		var p mediaPage
+		if err := c.get(ctx, next, &p); err != nil {
+			return nil, err
+		}
+		for _, d := range p.Data {
+			media = append(media, Media{
+				ID:           d.ID,
+				Caption:      d.Caption,
+				MediaType:    d.MediaType,
+				MediaURL:     d.MediaURL,
+				ThumbnailURL: d.ThumbnailURL,
+				Permalink:    d.Permalink,
+				Timestamp:    parseTime(d.Timestamp),
+			})
+		}
+		next = p.Paging.Next
+	}
+
+	if len(media) > limit {
+		media = media[:limit]
+	}
+	return media, nil
+}
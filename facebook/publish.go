@@ -0,0 +1,88 @@
+package facebook
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Publication is a post to publish on a Facebook page
+type Publication struct {
+	Message  string
+	Link     string
+	ImageURL string
+}
+
+// Publisher publishes posts to a Facebook page
+type Publisher interface {
+	// PublishPost publishes p on the page's feed and returns the ID of the
+	// new Facebook post
+	PublishPost(ctx context.Context, pageID string, p Publication) (string, error)
+}
+
+// PublishPost implements Publisher. Posts with an image are published as a
+// photo with the message and link as its caption, so the image is shown
+// full size; others as a link post. The client needs a page access token
+// with the pages_manage_posts permission.
+func (c *GraphClient) PublishPost(ctx context.Context, pageID string, p Publication) (string, error) {
+	if c.AccessToken == "" {
+		return "", ErrNoAccessToken
+	}
+
+	form := url.Values{}
+	edge := "feed"
+	if p.ImageURL != "" {
+		edge = "photos"
+		form.Set("url", p.ImageURL)
+		form.Set("caption", p.text())
+	} else {
+		form.Set("message", p.Message)
+		if p.Link != "" {
+			form.Set("link", p.Link)
+		}
+	}
+
+	var resp struct {
+		ID     string `json:"id"`
+		PostID string `json:"post_id"`
+	}
+	if err := c.post(ctx, c.endpoint(url.PathEscape(pageID)+"/"+edge, nil), form, &resp); err != nil {
+		return "", err
+	}
+	// Photos return the photo ID in id and the feed post in post_id
+	if resp.PostID != "" {
+		return resp.PostID, nil
+	}
+	return resp.ID, nil
+}
+
+// FindPublished looks for p among the page's newest posts created since
+// since and returns its ID, or "" if it is not there. It tells whether an
+// attempt whose answer was lost, such as one that timed out, went through.
+func (c *GraphClient) FindPublished(ctx context.Context, pageID string, p Publication, since time.Time) (string, error) {
+	posts, err := c.PagePosts(ctx, pageID, 10)
+	if err != nil {
+		return "", err
+	}
+	// Allow for the clocks of the site and Facebook to differ a little
+	since = since.Add(-5 * time.Minute)
+	for _, post := range posts {
+		if post.CreatedTime.Before(since) {
+			continue
+		}
+		if strings.TrimSpace(post.Message) == p.text() {
+			return post.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// text is the post's text as it appears on Facebook: the message, with the
+// link added to it for photos, whose caption is all the text they have
+func (p Publication) text() string {
+	if p.ImageURL == "" || p.Link == "" {
+		return strings.TrimSpace(p.Message)
+	}
+	return strings.TrimSpace(p.Message + "\n\n" + p.Link)
+}
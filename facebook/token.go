@@ -0,0 +1,171 @@
+package facebook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNoAppCredentials is returned by calls that need the app ID and secret
+var ErrNoAppCredentials = errors.New("missing_app_credentials")
+
+// ErrPageNotManaged is returned by PageToken when the user token gives no
+// access to the page
+var ErrPageNotManaged = errors.New("page_not_managed")
+
+// TokenInfo describes an access token as reported by /debug_token
+type TokenInfo struct {
+	AppID               string    `json:"app_id,omitempty"`
+	Application         string    `json:"application,omitempty"`
+	Type                string    `json:"type,omitempty"`
+	UserID              string    `json:"user_id,omitempty"`
+	ProfileID           string    `json:"profile_id,omitempty"`
+	IsValid             bool      `json:"is_valid"`
+	ExpiresAt           time.Time `json:"expires_at"`
+	DataAccessExpiresAt time.Time `json:"data_access_expires_at"`
+	Scopes              []string  `json:"scopes,omitempty"`
+	Error               string    `json:"error,omitempty"`
+}
+
+// NeverExpires reports whether the token has no expiry, as page tokens
+// derived from a long-lived user token do
+func (t *TokenInfo) NeverExpires() bool {
+	return t.IsValid && t.ExpiresAt.IsZero()
+}
+
+// LongLivedToken is the result of exchanging a short-lived token
+type LongLivedToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// DebugToken inspects inputToken. It authenticates with the app token when
+// the app ID and secret are set, and with the token itself otherwise.
+func (c *GraphClient) DebugToken(ctx context.Context, inputToken string) (*TokenInfo, error) {
+	if inputToken == "" {
+		return nil, ErrNoAccessToken
+	}
+
+	auth := inputToken
+	if c.AppID != "" && c.AppSecret != "" {
+		auth = c.AppID + "|" + c.AppSecret
+	}
+
+	q := url.Values{}
+	q.Set("input_token", inputToken)
+	var resp struct {
+		Data struct {
+			AppID               string   `json:"app_id"`
+			Application         string   `json:"application"`
+			Type                string   `json:"type"`
+			UserID              string   `json:"user_id"`
+			ProfileID           string   `json:"profile_id"`
+			IsValid             bool     `json:"is_valid"`
+			ExpiresAt           int64    `json:"expires_at"`
+			DataAccessExpiresAt int64    `json:"data_access_expires_at"`
+			Scopes              []string `json:"scopes"`
+			Error               *Error   `json:"error"`
+		} `json:"data"`
+	}
+	if err := c.getWithToken(ctx, c.endpoint("debug_token", q), auth, &resp); err != nil {
+		return nil, err
+	}
+
+	d := resp.Data
+	info := &TokenInfo{
+		AppID:               d.AppID,
+		Application:         d.Application,
+		Type:                d.Type,
+		UserID:              d.UserID,
+		ProfileID:           d.ProfileID,
+		IsValid:             d.IsValid,
+		ExpiresAt:           unixTime(d.ExpiresAt),
+		DataAccessExpiresAt: unixTime(d.DataAccessExpiresAt),
+		Scopes:              d.Scopes,
+	}
+	if d.Error != nil {
+		info.Error = d.Error.Message
+	}
+	return info, nil
+}
+
+// ExchangeToken trades a short-lived user token for a long-lived one
+func (c *GraphClient) ExchangeToken(ctx context.Context, shortLived string) (*LongLivedToken, error) {
+	if shortLived == "" {
+		return nil, ErrNoAccessToken
+	}
+	if c.AppID == "" || c.AppSecret == "" {
+		return nil, ErrNoAppCredentials
+	}
+
+	q := url.Values{}
+	q.Set("grant_type", "fb_exchange_token")
+	q.Set("client_id", c.AppID)
+	q.Set("client_secret", c.AppSecret)
+	q.Set("fb_exchange_token", shortLived)
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := c.getWithToken(ctx, c.endpoint("oauth/access_token", q), "", &resp); err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" {
+		return nil, fmt.Errorf("facebook: exchange returned no token")
+	}
+
+	token := &LongLivedToken{AccessToken: resp.AccessToken, TokenType: resp.TokenType}
+	if resp.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// PageToken returns the access token of the page pageID from the pages the
+// user manages (/me/accounts). pageID may be the page's numeric ID or its
+// username, which is matched regardless of case. A page token got with a
+// long-lived user token does not expire.
+func (c *GraphClient) PageToken(ctx context.Context, userToken, pageID string) (string, error) {
+	if userToken == "" {
+		return "", ErrNoAccessToken
+	}
+
+	q := url.Values{}
+	q.Set("fields", "id,username,access_token")
+	next := c.endpoint("me/accounts", q)
+	for page := 0; next != "" && page < maxPages; page++ {
+		var resp struct {
+			Data []struct {
+				ID          string `json:"id"`
+				Username    string `json:"username"`
+				AccessToken string `json:"access_token"`
+			} `json:"data"`
+			Paging struct {
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+		if err := c.getWithToken(ctx, next, userToken, &resp); err != nil {
+			return "", err
+		}
+		for _, d := range resp.Data {
+			matches := d.ID == pageID || (d.Username != "" && strings.EqualFold(d.Username, pageID))
+			if matches && d.AccessToken != "" {
+				return d.AccessToken, nil
+			}
+		}
+		next = resp.Paging.Next
+	}
+	return "", ErrPageNotManaged
+}
+
+func unixTime(sec int64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
@@ -0,0 +1,127 @@
+package facebook_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/facebook/facebooktest"
+)
+
+func TestDebugToken(t *testing.T) {
+	srv := facebooktest.NewServer()
+	defer srv.Close()
+	expires := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	srv.SetTokenInfo("user", facebook.TokenInfo{Type: "USER", IsValid: true, ExpiresAt: expires, Scopes: []string{"pages_show_list"}})
+	srv.SetTokenInfo("page", facebook.TokenInfo{Type: "PAGE", IsValid: true})
+	srv.SetTokenInfo("revoked", facebook.TokenInfo{Type: "USER"})
+
+	tests := []struct {
+		token       string
+		wantErr     error
+		wantValid   bool
+		wantExpires time.Time
+		wantNever   bool
+	}{
+		{token: "user", wantValid: true, wantExpires: expires},
+		{token: "page", wantValid: true, wantNever: true},
+		{token: "revoked"},
+		{token: "unknown"},
+		{token: "", wantErr: facebook.ErrNoAccessToken},
+	}
+	for _, tt := range tests {
+		info, err := srv.Client().DebugToken(context.Background(), tt.token)
+		if tt.wantErr != nil || err != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DebugToken(%q) error = %v, want %v", tt.token, err, tt.wantErr)
+			}
+			continue
+		}
+		if info.IsValid != tt.wantValid || !info.ExpiresAt.Equal(tt.wantExpires) || info.NeverExpires() != tt.wantNever {
+			t.Errorf("DebugToken(%q) = valid %v, expires %v, never expires %v", tt.token, info.IsValid, info.ExpiresAt, info.NeverExpires())
+		}
+	}
+}
+
+func TestExchangeToken(t *testing.T) {
+	srv := facebooktest.NewServer()
+	defer srv.Close()
+	srv.AppID, srv.AppSecret = "app", "secret"
+
+	tests := []struct {
+		name      string
+		appID     string
+		appSecret string
+		token     string
+		want      string
+		wantErr   bool
+	}{
+		{"exchanged", "app", "secret", "short", "long-lived-short", false},
+		{"no token", "app", "secret", "", "", true},
+		{"no app", "", "", "short", "", true},
+		{"wrong secret", "app", "wrong", "short", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := srv.Client()
+			client.AppID, client.AppSecret = tt.appID, tt.appSecret
+			long, err := client.ExchangeToken(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if long.AccessToken != tt.want {
+				t.Errorf("token %q, want %q", long.AccessToken, tt.want)
+			}
+			if left := time.Until(long.ExpiresAt); left < 59*24*time.Hour || left > 60*24*time.Hour {
+				t.Errorf("expires in %v, want 60 days", left)
+			}
+		})
+	}
+}
+
+func TestPageToken(t *testing.T) {
+	srv := facebooktest.NewServer()
+	defer srv.Close()
+	srv.PageID = "1234"
+	srv.PageUsername = "somamayel"
+	srv.SetTokenInfo("user", facebook.TokenInfo{Type: "USER", IsValid: true})
+	srv.SetTokenInfo("expired-user", facebook.TokenInfo{Type: "USER"})
+	srv.SetTokenInfo("page", facebook.TokenInfo{Type: "PAGE", IsValid: true})
+
+	tests := []struct {
+		name      string
+		token     string
+		pageID    string
+		want      string
+		wantErr   error
+		wantGraph bool // a Graph error rather than one of wantErr
+	}{
+		{name: "managed page", token: "user", pageID: "1234", want: "page-user"},
+		{name: "managed page by username", token: "user", pageID: "SomaMayel", want: "page-user"},
+		{name: "other page", token: "user", pageID: "9999", wantErr: facebook.ErrPageNotManaged},
+		{name: "other username", token: "user", pageID: "SomamayelRV", wantErr: facebook.ErrPageNotManaged},
+		{name: "no token", token: "", pageID: "1234", wantErr: facebook.ErrNoAccessToken},
+		{name: "expired user token", token: "expired-user", pageID: "1234", wantGraph: true},
+		{name: "page token", token: "page", pageID: "1234", wantGraph: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := srv.Client().PageToken(context.Background(), tt.token, tt.pageID)
+			switch {
+			case tt.wantGraph:
+				if !facebook.IsTokenExpired(err) {
+					t.Errorf("error = %v, want an invalid token error from Graph", err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			case got != tt.want:
+				t.Errorf("token %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,49 +1,46 @@
 package handlers
 
 import (
+	"soma-mayel-campaign/i18n"
+
 	"github.com/gofiber/fiber/v2"
 )
 
+// Defaults shown until staff save the about page in Tina. Their text is in
+// the catalogues under about.timeline_<year>_title and _text, and
+// about.role_<icon>.
+var (
+	aboutTimelineYears = []string{"1993", "2001", "2015", "2019", "2021", "2024"}
+	aboutRoleIcons     = []string{"sports", "economy", "accessibility", "education"}
+)
+
 func About(c *fiber.Ctx) error {
+	locale := i18n.FromCtx(c)
+	page := pageContent("about", locale)
+
+	timeline := make([]fiber.Map, 0, len(aboutTimelineYears))
+	for _, year := range aboutTimelineYears {
+		timeline = append(timeline, fiber.Map{
+			"Year":        year,
+			"Title":       i18n.T(locale, "about.timeline_"+year+"_title"),
+			"Description": i18n.T(locale, "about.timeline_"+year+"_text"),
+		})
+	}
+	roles := make([]fiber.Map, 0, len(aboutRoleIcons))
+	for _, icon := range aboutRoleIcons {
+		roles = append(roles, fiber.Map{"Title": i18n.T(locale, "about.role_"+icon), "Icon": icon})
+	}
+
 	return c.Render("about", fiber.Map{
-		"Title": "Om Soma Mayel",
-		"Timeline": []fiber.Map{
-			{
-				"Year":        "1993",
-				"Title":       "Født i Afghanistan",
-				"Description": "Født i Mazar-e Sharif, Afghanistan den 24. juni",
-			},
-			{
-				"Year":        "2001",
-				"Title":       "Ankomst til Danmark",
-				"Description": "Kom til Danmark som 7-årig flygtning med sin familie",
-			},
-			{
-				"Year":        "2015",
-				"Title":       "Dansk statsborgerskab",
-				"Description": "Modtog dansk pas og startede karriere i Udlændingestyrelsen",
-			},
-			{
-				"Year":        "2019",
-				"Title":       "Cand.jur.",
-				"Description": "Afsluttede juridisk kandidatgrad ved Københavns Universitet",
-			},
-			{
-				"Year":        "2021",
-				"Title":       "Byrådsmedlem",
-				"Description": "Valgt til Fredensborg Byråd med 811 personlige stemmer",
-			},
-			{
-				"Year":        "2024",
-				"Title":       "Spidskandidat",
-				"Description": "Blev spidskandidat for Radikale Venstre i Fredensborg Kommune",
-			},
-		},
-		"Roles": []fiber.Map{
-			{"Title": "Formand for Fritids- og Idrætsudvalget", "Icon": "sports"},
-			{"Title": "Medlem af Økonomiudvalget", "Icon": "economy"},
-			{"Title": "Formand for Handicaprådet", "Icon": "accessibility"},
-			{"Title": "Underviser ved Københavns Universitet", "Icon": "education"},
-		},
+		"Title": contentString(page, i18n.T(locale, "about.title"), "title"),
+		"Timeline": contentList(page, "timeline", map[string]string{
+			"year":        "Year",
+			"title":       "Title",
+			"description": "Description",
+		}, timeline),
+		"Roles": contentList(page, "roles", map[string]string{
+			"title": "Title",
+			"icon":  "Icon",
+		}, roles),
 	})
-}
\ No newline at end of file
+}
@@ -4,12 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"soma-mayel-campaign/i18n"
 	"soma-mayel-campaign/models"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,8 +17,16 @@ import (
 
 // AdminPage renders the admin UI
 func AdminPage(c *fiber.Ctx) error {
+	var locales []i18n.Locale
+	for _, l := range i18n.Locales {
+		if l.Code != i18n.Default {
+			locales = append(locales, l)
+		}
+	}
+
 	return c.Render("admin", fiber.Map{
-		"Title": "Admin - Content Manager",
+		"Title":              "Admin - Content Manager",
+		"TranslationLocales": locales,
 	})
 }
 
@@ -42,16 +50,23 @@ func AdminGetPost(c *fiber.Ctx) error {
 }
 
 type upsertPostRequest struct {
-	ID         string   `json:"id"`
-	Title      string   `json:"title"`
-	Slug       string   `json:"slug"`
-	Content    string   `json:"content"`
-	Excerpt    string   `json:"excerpt"`
-	Author     string   `json:"author"`
-	Date       string   `json:"date"`
-	Image      string   `json:"image"`
-	Tags       []string `json:"tags"`
-	IsFeatured bool     `json:"is_featured"`
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Slug        string   `json:"slug"`
+	Content     string   `json:"content"`
+	Excerpt     string   `json:"excerpt"`
+	Author      string   `json:"author"`
+	Date        string   `json:"date"`
+	Image       string   `json:"image"`
+	Tags        []string `json:"tags"`
+	IsFeatured  bool     `json:"is_featured"`
+	PolicyAreas []string `json:"policy_areas"`
+	Draft       bool     `json:"draft"`
+
+	// PublishFacebook opts in to publishing the post on the Facebook page
+	PublishFacebook bool `json:"publish_facebook"`
+
+	Translations map[string]models.PostTranslation `json:"translations"`
 }
 
 // AdminUpsertPost creates or updates a post
@@ -82,25 +97,63 @@ func AdminUpsertPost(c *fiber.Ctx) error {
 	}
 
 	post := models.Post{
-		ID:         req.ID,
-		Title:      strings.TrimSpace(req.Title),
-		Slug:       strings.TrimSpace(req.Slug),
-		Content:    req.Content,
-		Excerpt:    req.Excerpt,
-		Author:     req.Author,
-		Date:       parsedDate,
-		Image:      req.Image,
-		Tags:       req.Tags,
-		IsFeatured: req.IsFeatured,
+		ID:          req.ID,
+		Title:       strings.TrimSpace(req.Title),
+		Slug:        strings.TrimSpace(req.Slug),
+		Content:     req.Content,
+		Excerpt:     req.Excerpt,
+		Author:      req.Author,
+		Date:        parsedDate,
+		Image:       req.Image,
+		Tags:        req.Tags,
+		IsFeatured:  req.IsFeatured,
+		PolicyAreas: req.PolicyAreas,
+		Draft:       req.Draft,
+
+		Translations: cleanPostTranslations(req.Translations),
+	}
+
+	// The Facebook link is managed by the importer and the publisher, not
+	// the editor form
+	if existing := models.GetPostByID(req.ID); req.ID != "" && existing != nil {
+		post.FacebookID = existing.FacebookID
+		post.FacebookURL = existing.FacebookURL
+		post.FacebookStatus = existing.FacebookStatus
+		post.FacebookError = existing.FacebookError
 	}
 
 	if err := models.SavePost(&post); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save post"})
+		return serverError(c, "failed to save post", err)
+	}
+
+	if req.PublishFacebook && !post.Draft && post.FacebookID == "" && post.FacebookStatus != facebookStatusQueued {
+		if err := queueFacebookPublish(&post, c.BaseURL()); err != nil {
+			return serverError(c, "post saved but could not be queued for Facebook", err)
+		}
 	}
 
 	return c.JSON(post)
 }
 
+// cleanPostTranslations drops translations for unknown locales and ones
+// where every field is empty
+func cleanPostTranslations(in map[string]models.PostTranslation) map[string]models.PostTranslation {
+	out := map[string]models.PostTranslation{}
+	for code, tr := range in {
+		if _, ok := i18n.Get(code); !ok || code == i18n.Default {
+			continue
+		}
+		if strings.TrimSpace(tr.Title+tr.Excerpt+tr.Content) == "" {
+			continue
+		}
+		out[code] = tr
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
 // AdminDeletePost deletes a post by id
 func AdminDeletePost(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -108,7 +161,7 @@ func AdminDeletePost(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "missing id"})
 	}
 	if err := models.DeletePost(id); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to delete post"})
+		return serverError(c, "failed to delete post", err)
 	}
 	return c.JSON(fiber.Map{"success": true})
 }
@@ -126,15 +179,17 @@ func AdminUpload(c *fiber.Ctx) error {
 	}
 	defer file.Close()
 
-	url, err := saveUpload(fileHeader.Filename, file)
+	url, err := saveUpload("admin", fileHeader.Filename, file)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to save upload"})
+		return serverError(c, "failed to save upload", err)
 	}
 
 	return c.JSON(fiber.Map{"url": url})
 }
 
-func saveUpload(originalName string, src multipart.File) (string, error) {
+// saveUpload stores an image under static/images/uploads and counts it
+// under source in the upload metrics
+func saveUpload(source, originalName string, src io.Reader) (string, error) {
 	// Ensure uploads directory exists
 	uploadDir := filepath.Join("./static", "images", "uploads")
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
@@ -156,9 +211,11 @@ func saveUpload(originalName string, src multipart.File) (string, error) {
 	}
 	defer dst.Close()
 
-	if _, err := io.Copy(dst, src); err != nil {
+	n, err := io.Copy(dst, src)
+	if err != nil {
 		return "", err
 	}
+	countUpload(source, n)
 
 	// Public URL
 	return "/static/images/uploads/" + filename, nil
@@ -182,4 +239,3 @@ func slugify(s string) string {
 	}
 	return res
 }
-
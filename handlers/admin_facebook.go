@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// facebookImportMaxImage caps the size of images downloaded on import
+	facebookImportMaxImage = 10 << 20
+	facebookTitleLength    = 80
+	facebookExcerptLength  = 160
+)
+
+type facebookImportResult struct {
+	Imported []models.Post `json:"imported"`
+	Skipped  int           `json:"skipped"`
+	Warnings []string      `json:"warnings"`
+}
+
+// AdminImportFacebookPosts pulls the page's latest Facebook posts and saves
+// each one not seen before as a draft post
+func (site *Site) AdminImportFacebookPosts(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 25)
+	if limit < 1 || limit > 100 {
+		limit = 25
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	fbPosts, err := site.facebookGraphClient().PagePosts(ctx, site.facebookPageID(), limit)
+	if err != nil {
+		reason, _ := socialFailure(err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": reason})
+	}
+
+	result := facebookImportResult{Imported: []models.Post{}, Warnings: []string{}}
+	for _, fp := range fbPosts {
+		if models.GetPostByFacebookID(fp.ID) != nil {
+			result.Skipped++
+			continue
+		}
+		if strings.TrimSpace(fp.Message) == "" && fp.FullPicture == "" {
+			result.Skipped++
+			continue
+		}
+
+		post := postFromFacebook(fp)
+		if fp.FullPicture != "" {
+			url, err := importFacebookImage(ctx, fp)
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s: image not imported: %v", fp.ID, err))
+			} else {
+				post.Image = url
+			}
+		}
+
+		if err := models.SavePost(&post); err != nil {
+			return serverError(c, "failed to save post", err)
+		}
+		result.Imported = append(result.Imported, post)
+	}
+
+	return c.JSON(result)
+}
+
+// postFromFacebook converts a Facebook post into a draft post. The title is
+// the first line of the message and the content the whole message.
+func postFromFacebook(fp facebook.Post) models.Post {
+	message := strings.TrimSpace(fp.Message)
+
+	title, rest := message, ""
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		title, rest = strings.TrimSpace(message[:i]), strings.TrimSpace(message[i+1:])
+	}
+	if title == "" {
+		title = "Facebook-opslag " + fp.CreatedTime.Format("2006-01-02")
+	}
+
+	excerpt := rest
+	if excerpt == "" {
+		excerpt = message
+	}
+
+	date := fp.CreatedTime
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	return models.Post{
+		ID:          "facebook-" + slugify(fp.ID),
+		Title:       truncateWords(title, facebookTitleLength),
+		Slug:        uniqueSlug(transliterate(truncateWords(title, 60))),
+		Content:     message,
+		Excerpt:     truncateWords(strings.Join(strings.Fields(excerpt), " "), facebookExcerptLength),
+		Author:      "Soma Mayel",
+		Date:        date,
+		Tags:        []string{"Facebook"},
+		Draft:       true,
+		FacebookID:  fp.ID,
+		FacebookURL: fp.PermalinkURL,
+	}
+}
+
+// importFacebookImage downloads the post's picture into the uploads folder
+// and returns its public URL
+func importFacebookImage(ctx context.Context, fp facebook.Post) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fp.FullPicture, nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", res.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	ext, ok := imageExtensions[mediaType]
+	if !ok {
+		return "", fmt.Errorf("unsupported content type %q", mediaType)
+	}
+	if res.ContentLength > facebookImportMaxImage {
+		return "", fmt.Errorf("image too large")
+	}
+
+	return saveUpload("facebook_import", "facebook-"+fp.ID+ext, io.LimitReader(res.Body, facebookImportMaxImage))
+}
+
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// truncateWords shortens s to at most n runes, cutting at a word boundary
+func truncateWords(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	cut := string([]rune(s)[:n-1])
+	// Only cut at a space in the second half, so one long word does not
+	// leave next to nothing
+	if i := strings.LastIndex(cut, " "); i >= 0 && utf8.RuneCountInString(cut[:i]) > n/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
+
+// transliterate spells out Danish letters so slugify keeps them
+func transliterate(s string) string {
+	return strings.NewReplacer("æ", "ae", "Æ", "ae", "ø", "o", "Ø", "o", "å", "aa", "Å", "aa").Replace(s)
+}
+
+// uniqueSlug returns slugify(title), suffixed with a number if a post
+// already uses it
+func uniqueSlug(title string) string {
+	base := strings.Trim(slugify(title), "-")
+	if base == "" || base == "file" {
+		base = "facebook"
+	}
+
+	taken := map[string]bool{}
+	for _, p := range models.GetAllPosts() {
+		taken[p.Slug] = true
+	}
+	slug := base
+	for i := 2; taken[slug]; i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	return slug
+}
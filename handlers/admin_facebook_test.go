@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/facebook/facebooktest"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTruncateWords(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{name: "short", in: "Stem på Soma", n: 20, want: "Stem på Soma"},
+		{name: "at a space", in: "Stem på Soma Mayel til valget", n: 16, want: "Stem på Soma…"},
+		{name: "drops punctuation", in: "Valgmøde, onsdag aften", n: 12, want: "Valgmøde…"},
+		{name: "long word", in: "Kommunalbestyrelsesmedlem Soma", n: 10, want: "Kommunalb…"},
+		// The space is past half of 12 in bytes but not in runes, so the
+		// word is cut rather than dropped
+		{name: "space early in runes", in: "ببببب بببببببببب", n: 12, want: "ببببب ببببب…"},
+		{name: "persian", in: "سلام به همه دوستان در فردنسبورگ", n: 15, want: "سلام به همه…"},
+		{name: "danish", in: "Ærø og Æbelø får færre færger", n: 14, want: "Ærø og Æbelø…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateWords(tt.in, tt.n)
+			if got != tt.want {
+				t.Errorf("truncateWords(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+			}
+			if n := len([]rune(got)); n > tt.n {
+				t.Errorf("%q is %d runes, longer than %d", got, n, tt.n)
+			}
+		})
+	}
+}
+
+func TestPostFromFacebook(t *testing.T) {
+	newTestSite(t, nil)
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	post := postFromFacebook(facebook.Post{
+		ID:           "1234_99",
+		Message:      "  Grøn omstilling i Fredensborg\n\nVi skal  have flere\ncykelstier.  ",
+		CreatedTime:  created,
+		PermalinkURL: "https://www.facebook.com/1234/posts/99",
+	})
+	want := models.Post{
+		ID:          "facebook-1234-99",
+		Title:       "Grøn omstilling i Fredensborg",
+		Slug:        "gron-omstilling-i-fredensborg",
+		Content:     "Grøn omstilling i Fredensborg\n\nVi skal  have flere\ncykelstier.",
+		Excerpt:     "Vi skal have flere cykelstier.",
+		Author:      "Soma Mayel",
+		Date:        created,
+		Draft:       true,
+		FacebookID:  "1234_99",
+		FacebookURL: "https://www.facebook.com/1234/posts/99",
+	}
+	if post.ID != want.ID || post.Title != want.Title || post.Slug != want.Slug || post.Content != want.Content ||
+		post.Excerpt != want.Excerpt || post.Author != want.Author || !post.Date.Equal(want.Date) || !post.Draft ||
+		post.FacebookID != want.FacebookID || post.FacebookURL != want.FacebookURL {
+		t.Errorf("post\n %+v\nwant\n %+v", post, want)
+	}
+	if len(post.Tags) != 1 || post.Tags[0] != "Facebook" {
+		t.Errorf("tags %v, want [Facebook]", post.Tags)
+	}
+
+	// A picture without a message is titled by its date
+	photo := postFromFacebook(facebook.Post{ID: "1234_100", CreatedTime: created})
+	if photo.Title != "Facebook-opslag 2026-05-01" || photo.Slug != "facebook-opslag-2026-05-01" {
+		t.Errorf("photo post title %q, slug %q", photo.Title, photo.Slug)
+	}
+}
+
+func TestAdminImportFacebookPosts(t *testing.T) {
+	site, srv := newFacebookSite(t)
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/photo.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer images.Close()
+
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	srv.SetPosts(
+		facebook.Post{ID: testPageID + "_3", Message: "Valgmøde\nKom og hør om grøn omstilling", CreatedTime: created, FullPicture: images.URL + "/photo.png"},
+		facebook.Post{ID: testPageID + "_2", Message: "Billedet mangler", CreatedTime: created.Add(-time.Hour), FullPicture: images.URL + "/missing.png"},
+		facebook.Post{ID: testPageID + "_1", Message: "   ", CreatedTime: created.Add(-2 * time.Hour)},
+	)
+
+	app := fiber.New()
+	app.Post("/api/admin/facebook/import", site.AdminImportFacebookPosts)
+
+	var result facebookImportResult
+	if code := request(t, app, "POST", "/api/admin/facebook/import", "", &result); code != fiber.StatusOK {
+		t.Fatalf("import answered %d", code)
+	}
+	if len(result.Imported) != 2 || result.Skipped != 1 {
+		t.Fatalf("imported %d, skipped %d, want 2 and 1", len(result.Imported), result.Skipped)
+	}
+	if len(result.Warnings) != 1 || !strings.HasPrefix(result.Warnings[0], testPageID+"_2: image not imported") {
+		t.Errorf("warnings %v, want one for the missing image", result.Warnings)
+	}
+
+	saved := models.GetPostByFacebookID(testPageID + "_3")
+	if saved == nil || !saved.Draft || saved.Title != "Valgmøde" {
+		t.Fatalf("saved post %+v, want a draft titled Valgmøde", saved)
+	}
+	if !strings.HasPrefix(saved.Image, "/static/images/uploads/facebook-") {
+		t.Errorf("image %q, want an upload", saved.Image)
+	} else if _, err := os.Stat("." + saved.Image); err != nil {
+		t.Errorf("image not saved: %v", err)
+	}
+
+	// Posts imported before are skipped
+	if code := request(t, app, "POST", "/api/admin/facebook/import", "", &result); code != fiber.StatusOK {
+		t.Fatalf("second import answered %d", code)
+	}
+	if len(result.Imported) != 0 || result.Skipped != 3 {
+		t.Errorf("second import: imported %d, skipped %d, want 0 and 3", len(result.Imported), result.Skipped)
+	}
+
+	srv.FailWith(facebooktest.ExpiredToken())
+	var failure struct {
+		Error string `json:"error"`
+	}
+	if code := request(t, app, "POST", "/api/admin/facebook/import", "", &failure); code != fiber.StatusBadGateway || failure.Error != "token_expired" {
+		t.Errorf("import with an expired token answered %d %q, want 502 token_expired", code, failure.Error)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+
+	"soma-mayel-campaign/i18n"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminListPolicyAreas returns all policy areas as JSON
+func AdminListPolicyAreas(c *fiber.Ctx) error {
+	return c.JSON(models.GetAllPolicyAreas())
+}
+
+// AdminGetPolicyArea returns a single policy area by id
+func AdminGetPolicyArea(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "missing id"})
+	}
+	area := models.GetPolicyAreaByID(id)
+	if area == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "policy area not found"})
+	}
+	return c.JSON(area)
+}
+
+type upsertPolicyAreaRequest struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Slug        string   `json:"slug"`
+	Order       int      `json:"order"`
+	Icon        string   `json:"icon"`
+	Description string   `json:"description"`
+	Points      []string `json:"points"`
+	Body        string   `json:"body"`
+
+	Translations map[string]models.PolicyAreaTranslation `json:"translations"`
+}
+
+// AdminUpsertPolicyArea creates or updates a policy area
+func AdminUpsertPolicyArea(c *fiber.Ctx) error {
+	var req upsertPolicyAreaRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+
+	if strings.TrimSpace(req.Title) == "" || strings.TrimSpace(req.Slug) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "title and slug are required"})
+	}
+
+	// Slugs must be unique since they appear in /politik/:slug
+	if existing := models.GetPolicyAreaBySlug(strings.TrimSpace(req.Slug)); existing != nil && existing.ID != req.ID {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "slug already in use"})
+	}
+
+	var points []string
+	for _, p := range req.Points {
+		if p = strings.TrimSpace(p); p != "" {
+			points = append(points, p)
+		}
+	}
+
+	area := models.PolicyArea{
+		ID:          req.ID,
+		Title:       strings.TrimSpace(req.Title),
+		Slug:        strings.TrimSpace(req.Slug),
+		Order:       req.Order,
+		Icon:        req.Icon,
+		Description: req.Description,
+		Points:      points,
+		Body:        req.Body,
+
+		Translations: cleanPolicyAreaTranslations(req.Translations),
+	}
+
+	if err := models.SavePolicyArea(&area); err != nil {
+		return serverError(c, "failed to save policy area", err)
+	}
+
+	return c.JSON(area)
+}
+
+// cleanPolicyAreaTranslations drops translations for unknown locales and
+// ones where every field is empty
+func cleanPolicyAreaTranslations(in map[string]models.PolicyAreaTranslation) map[string]models.PolicyAreaTranslation {
+	out := map[string]models.PolicyAreaTranslation{}
+	for code, tr := range in {
+		if _, ok := i18n.Get(code); !ok || code == i18n.Default {
+			continue
+		}
+		var points []string
+		for _, p := range tr.Points {
+			if p = strings.TrimSpace(p); p != "" {
+				points = append(points, p)
+			}
+		}
+		tr.Points = points
+		if strings.TrimSpace(tr.Title+tr.Description+tr.Body) == "" && len(points) == 0 {
+			continue
+		}
+		out[code] = tr
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// AdminDeletePolicyArea deletes a policy area by id
+func AdminDeletePolicyArea(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "missing id"})
+	}
+	if err := models.DeletePolicyArea(id); err != nil {
+		return serverError(c, "failed to delete policy area", err)
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
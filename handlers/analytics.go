@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/i18n"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+const (
+	analyticsFlushInterval = time.Minute
+	// analyticsMaxKeys bounds the distinct pages and sources counted per
+	// day; the rest are counted as "other"
+	analyticsMaxKeys = 500
+	// analyticsMaxDays is the longest period the dashboard reports on
+	analyticsMaxDays = 366
+)
+
+// botMarkers identify crawlers and link previews, which are not counted
+var botMarkers = []string{"bot", "crawl", "spider", "slurp", "facebookexternalhit", "headless", "preview", "curl", "wget", "python-", "go-http-client"}
+
+// pageViews holds the counts not yet written to disk, per day
+var pageViews struct {
+	mu   sync.Mutex
+	days map[string]*models.DailyStats
+}
+
+type analyticsEntry struct {
+	Key   string `json:"key"`
+	Title string `json:"title,omitempty"`
+	Views int    `json:"views"`
+}
+
+// Analytics returns middleware that counts successful page views by page,
+// referring site, device class and language. Nothing is stored about the
+// visitor: no cookies are set and IP addresses are not recorded. It also
+// makes GA_TRACKING_ID available to the layout, which only loads Google
+// Analytics after the visitor accepts it in the consent banner.
+func (site *Site) Analytics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Bind(fiber.Map{"GATrackingID": site.cfg.Analytics.GATrackingID}); err != nil {
+			return err
+		}
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		if c.Method() != fiber.MethodGet || c.Response().StatusCode() != fiber.StatusOK ||
+			!strings.HasPrefix(string(c.Response().Header.ContentType()), fiber.MIMETextHTML) {
+			return nil
+		}
+		// Prefetches are not views
+		if c.Get("Sec-Purpose") != "" || c.Get("Purpose") == "prefetch" {
+			return nil
+		}
+		device := deviceClass(c.Get(fiber.HeaderUserAgent))
+		if device == "" {
+			return nil
+		}
+
+		_, path := i18n.SplitPath(c.Path())
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		if strings.HasPrefix(path, "/admin") {
+			return nil
+		}
+		// Request strings are reused by fiber after the handler returns
+		recordPageView(time.Now(), utils.CopyString(path), utils.CopyString(trafficSource(c)), device, i18n.FromCtx(c))
+		return nil
+	}
+}
+
+// StartAnalytics writes the counted page views to the daily aggregates in
+// data/analytics every minute, and once more when ctx is cancelled
+func StartAnalytics(ctx context.Context) {
+	goWorker(func() {
+		ticker := time.NewTicker(analyticsFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flushAnalytics()
+			case <-ctx.Done():
+				flushAnalytics()
+				return
+			}
+		}
+	})
+}
+
+// AdminAnalytics returns page views for the last ?days= days (default 30):
+// totals per day, the most viewed pages and posts, traffic sources, device
+// classes and languages
+func AdminAnalytics(c *fiber.Ctx) error {
+	flushAnalytics()
+
+	days := c.QueryInt("days", 30)
+	if days < 1 || days > analyticsMaxDays {
+		days = 30
+	}
+	limit := c.QueryInt("limit", 10)
+
+	now := time.Now()
+	total := models.NewDailyStats("")
+	daily := make([]fiber.Map, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		stats := models.GetDailyStats(date)
+		total.Add(stats)
+		daily = append(daily, fiber.Map{"date": date, "views": stats.Views})
+	}
+
+	// Blog posts are counted under their slug in every language
+	posts := map[string]int{}
+	for path, n := range total.Paths {
+		if slug := strings.TrimPrefix(path, "/blog/"); slug != path {
+			posts[slug] += n
+		}
+	}
+	topPosts := topEntries(posts, limit)
+	for i := range topPosts {
+		if p := models.GetPostBySlug(topPosts[i].Key); p != nil {
+			topPosts[i].Title = p.Title
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"from":      daily[0]["date"],
+		"to":        daily[len(daily)-1]["date"],
+		"views":     total.Views,
+		"daily":     daily,
+		"top_pages": topEntries(total.Paths, limit),
+		"top_posts": topPosts,
+		"sources":   topEntries(total.Referrers, limit),
+		"devices":   total.Devices,
+		"locales":   total.Locales,
+	})
+}
+
+func recordPageView(now time.Time, path, source, device, locale string) {
+	date := now.Format("2006-01-02")
+
+	pageViews.mu.Lock()
+	defer pageViews.mu.Unlock()
+	if pageViews.days == nil {
+		pageViews.days = map[string]*models.DailyStats{}
+	}
+	stats := pageViews.days[date]
+	if stats == nil {
+		s := models.NewDailyStats(date)
+		stats = &s
+		pageViews.days[date] = stats
+	}
+	stats.Views++
+	countKey(stats.Paths, path)
+	countKey(stats.Referrers, source)
+	stats.Devices[device]++
+	stats.Locales[locale]++
+}
+
+// countKey counts key, or "other" once a day has analyticsMaxKeys keys
+func countKey(m map[string]int, key string) {
+	if _, ok := m[key]; !ok && len(m) >= analyticsMaxKeys {
+		key = "other"
+	}
+	m[key]++
+}
+
+// flushAnalytics adds the pending counts to the daily files
+func flushAnalytics() {
+	pageViews.mu.Lock()
+	pending := pageViews.days
+	pageViews.days = nil
+	pageViews.mu.Unlock()
+
+	for date, counts := range pending {
+		stats := models.GetDailyStats(date)
+		stats.Add(*counts)
+		if err := models.SaveDailyStats(stats); err != nil {
+			slog.Error("analytics: could not save daily stats", "date", date, "err", err)
+			// Keep the counts for the next flush
+			pageViews.mu.Lock()
+			if pageViews.days == nil {
+				pageViews.days = map[string]*models.DailyStats{}
+			}
+			if current := pageViews.days[date]; current != nil {
+				counts.Add(*current)
+			}
+			pageViews.days[date] = counts
+			pageViews.mu.Unlock()
+		}
+	}
+}
+
+// trafficSource is the utm_source of a link, the domain of the referring
+// site, "internal" for links within the site, or "direct" when there is no
+// referrer
+func trafficSource(c *fiber.Ctx) string {
+	if source := strings.ToLower(strings.TrimSpace(c.Query("utm_source"))); source != "" {
+		if len(source) > 64 {
+			source = source[:64]
+		}
+		return source
+	}
+	ref := c.Get(fiber.HeaderReferer)
+	if ref == "" {
+		return "direct"
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Hostname() == "" {
+		return "direct"
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	self := strings.TrimPrefix(strings.ToLower(strings.Split(c.Hostname(), ":")[0]), "www.")
+	if host == self {
+		return "internal"
+	}
+	return host
+}
+
+// deviceClass is mobile, tablet or desktop, or "" for bots
+func deviceClass(ua string) string {
+	ua = strings.ToLower(ua)
+	if ua == "" {
+		return ""
+	}
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			return ""
+		}
+	}
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") || (strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return "tablet"
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		return "mobile"
+	}
+	return "desktop"
+}
+
+// topEntries returns the limit largest counts, largest first
+func topEntries(counts map[string]int, limit int) []analyticsEntry {
+	entries := make([]analyticsEntry, 0, len(counts))
+	for k, n := range counts {
+		entries = append(entries, analyticsEntry{Key: k, Views: n})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Views != entries[j].Views {
+			return entries[i].Views > entries[j].Views
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
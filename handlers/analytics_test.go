@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"soma-mayel-campaign/i18n"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	mobileUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148"
+)
+
+func TestDeviceClass(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want string
+	}{
+		{ua: desktopUA, want: "desktop"},
+		{ua: mobileUA, want: "mobile"},
+		{ua: "Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36", want: "mobile"},
+		{ua: "Mozilla/5.0 (Linux; Android 13; SM-X200) Safari/537.36", want: "tablet"},
+		{ua: "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X)", want: "tablet"},
+		{ua: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", want: ""},
+		{ua: "facebookexternalhit/1.1", want: ""},
+		{ua: "curl/8.4.0", want: ""},
+		{ua: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := deviceClass(tt.ua); got != tt.want {
+			t.Errorf("deviceClass(%q) = %q, want %q", tt.ua, got, tt.want)
+		}
+	}
+}
+
+// newAnalyticsApp returns an app counting views of HTML pages under /, a
+// JSON endpoint, an admin page and the analytics API
+func newAnalyticsApp(t *testing.T) *fiber.App {
+	t.Helper()
+	site := newTestSite(t, nil)
+	models.SavePost(&models.Post{ID: "gron-omstilling", Slug: "gron-omstilling", Title: "Grøn omstilling"})
+
+	app := fiber.New()
+	app.Use(i18n.New())
+	app.Use(site.Analytics())
+	app.Get("/api/admin/analytics", AdminAnalytics)
+	app.Get("/api/posts", func(c *fiber.Ctx) error { return c.JSON(fiber.Map{}) })
+	app.Get("/*", func(c *fiber.Ctx) error {
+		if c.Path() == "/findes-ikke" {
+			return c.SendStatus(fiber.StatusNotFound)
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.SendString("<html></html>")
+	})
+	return app
+}
+
+// visit requests path with the given headers, as name/value pairs
+func visit(t *testing.T, app *fiber.App, path string, headers ...string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "http://example.org"+path, nil)
+	req.Header.Set(fiber.HeaderUserAgent, desktopUA)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestAnalytics(t *testing.T) {
+	app := newAnalyticsApp(t)
+
+	visit(t, app, "/blog/gron-omstilling", fiber.HeaderReferer, "https://www.facebook.com/somamayel")
+	visit(t, app, "/en/blog/gron-omstilling/?utm_source=Newsletter", fiber.HeaderUserAgent, mobileUA)
+	visit(t, app, "/", fiber.HeaderReferer, "https://www.example.org/om")
+	visit(t, app, "/fa/")
+	// Not counted
+	visit(t, app, "/", fiber.HeaderUserAgent, "Mozilla/5.0 (compatible; bingbot/2.0)")
+	visit(t, app, "/", "Sec-Purpose", "prefetch")
+	visit(t, app, "/findes-ikke")
+	visit(t, app, "/api/posts")
+	visit(t, app, "/admin/posts")
+
+	var report struct {
+		Views    int              `json:"views"`
+		Daily    []fiber.Map      `json:"daily"`
+		TopPages []analyticsEntry `json:"top_pages"`
+		TopPosts []analyticsEntry `json:"top_posts"`
+		Sources  []analyticsEntry `json:"sources"`
+		Devices  map[string]int   `json:"devices"`
+		Locales  map[string]int   `json:"locales"`
+	}
+	if code := request(t, app, "GET", "/api/admin/analytics?days=7", "", &report); code != fiber.StatusOK {
+		t.Fatalf("analytics answered %d", code)
+	}
+	if report.Views != 4 || len(report.Daily) != 7 {
+		t.Errorf("%d views over %d days, want 4 over 7", report.Views, len(report.Daily))
+	}
+	want := []analyticsEntry{{Key: "/", Views: 2}, {Key: "/blog/gron-omstilling", Views: 2}}
+	if fmt.Sprint(report.TopPages) != fmt.Sprint(want) {
+		t.Errorf("top pages %+v, want %+v", report.TopPages, want)
+	}
+	// A post is counted in every language
+	if len(report.TopPosts) != 1 || report.TopPosts[0] != (analyticsEntry{Key: "gron-omstilling", Title: "Grøn omstilling", Views: 2}) {
+		t.Errorf("top posts %+v", report.TopPosts)
+	}
+	sources := map[string]int{}
+	for _, e := range report.Sources {
+		sources[e.Key] = e.Views
+	}
+	if fmt.Sprint(sources) != fmt.Sprint(map[string]int{"direct": 1, "facebook.com": 1, "internal": 1, "newsletter": 1}) {
+		t.Errorf("sources %v", sources)
+	}
+	if report.Devices["desktop"] != 3 || report.Devices["mobile"] != 1 {
+		t.Errorf("devices %v", report.Devices)
+	}
+	if report.Locales["da"] != 2 || report.Locales["en"] != 1 || report.Locales["fa"] != 1 {
+		t.Errorf("locales %v", report.Locales)
+	}
+
+	// The report flushed the counts, and later views add to them
+	today := time.Now().Format("2006-01-02")
+	if stats := models.GetDailyStats(today); stats.Views != 4 {
+		t.Errorf("saved %d views, want 4", stats.Views)
+	}
+	visit(t, app, "/")
+	flushAnalytics()
+	if stats := models.GetDailyStats(today); stats.Views != 5 || stats.Paths["/"] != 3 {
+		t.Errorf("after another view: %d views, %d of /", stats.Views, stats.Paths["/"])
+	}
+}
+
+func TestFlushAnalyticsKeepsCountsOnError(t *testing.T) {
+	newTestSite(t, nil)
+	now := time.Now()
+	today := now.Format("2006-01-02")
+
+	// A file where the directory should be makes saving fail
+	if err := os.MkdirAll("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("data/analytics", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	recordPageView(now, "/", "direct", "desktop", "da")
+	flushAnalytics()
+	recordPageView(now, "/", "direct", "mobile", "da")
+
+	os.Remove("data/analytics")
+	flushAnalytics()
+	stats := models.GetDailyStats(today)
+	if stats.Views != 2 || stats.Devices["desktop"] != 1 || stats.Devices["mobile"] != 1 {
+		t.Errorf("saved %d views on %v, want both views", stats.Views, stats.Devices)
+	}
+}
+
+func TestRecordPageViewCapsKeys(t *testing.T) {
+	newTestSite(t, nil)
+	now := time.Now()
+	for i := 0; i < analyticsMaxKeys+10; i++ {
+		recordPageView(now, fmt.Sprintf("/side-%d", i), "direct", "desktop", "da")
+	}
+	recordPageView(now, "/side-0", "direct", "desktop", "da")
+	flushAnalytics()
+
+	stats := models.GetDailyStats(now.Format("2006-01-02"))
+	if len(stats.Paths) != analyticsMaxKeys+1 || stats.Paths["other"] != 10 || stats.Paths["/side-0"] != 2 {
+		t.Errorf("%d paths, %d other, %d of /side-0; want %d, 10 and 2", len(stats.Paths), stats.Paths["other"], stats.Paths["/side-0"], analyticsMaxKeys+1)
+	}
+}
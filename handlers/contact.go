@@ -1,17 +1,23 @@
 package handlers
 
 import (
+	"soma-mayel-campaign/i18n"
+
 	"github.com/gofiber/fiber/v2"
 )
 
 func Contact(c *fiber.Ctx) error {
+	locale := i18n.FromCtx(c)
+	siteContent := siteSettings(locale)
+	page := pageContent("contact", locale)
+
 	return c.Render("contact", fiber.Map{
-		"Title": "Kontakt Soma Mayel",
+		"Title": contentString(page, i18n.T(locale, "contact.title"), "title"),
 		"ContactInfo": fiber.Map{
-			"Email":    "soma@radikale-fredensborg.dk",
-			"Phone":    "+45 XX XX XX XX",
-			"Facebook": "https://www.facebook.com/somamayel",
-			"Address":  "Kokkedal, Fredensborg Kommune",
+			"Email":    contentString(siteContent, i18n.T(locale, "contact.default_email"), "contact", "email"),
+			"Phone":    contentString(siteContent, i18n.T(locale, "contact.default_phone"), "contact", "phone"),
+			"Facebook": contentString(siteContent, i18n.T(locale, "contact.default_facebook"), "contact", "facebook"),
+			"Address":  contentString(siteContent, i18n.T(locale, "contact.default_address"), "contact", "address"),
 		},
 	})
-}
\ No newline at end of file
+}
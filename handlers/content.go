@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"strings"
+
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Documents in the Tina collections that drive the public pages
+const (
+	settingsCollection = "settings"
+	settingsID         = "site"
+	pagesCollection    = "pages"
+)
+
+// siteSettings returns the data of the Tina "settings" document in the
+// given locale, or an empty map if staff have not saved any settings yet
+func siteSettings(locale string) map[string]interface{} {
+	return models.GetLocalizedContent(settingsCollection, settingsID, locale)
+}
+
+// pageContent returns the data of a Tina "page" document by id in the given
+// locale, or an empty map
+func pageContent(id, locale string) map[string]interface{} {
+	return models.GetLocalizedContent(pagesCollection, id, locale)
+}
+
+// siteURL returns the public address of the site for links that leave it,
+// such as in e-mails and shared posts. It falls back to the address of the
+// current request if SITE_URL is not set.
+func (site *Site) siteURL(requestBase string) string {
+	if site.cfg.Server.SiteURL != "" {
+		return site.cfg.Server.SiteURL
+	}
+	return strings.TrimRight(requestBase, "/")
+}
+
+// absoluteURL makes a site-relative link such as an uploaded image's path
+// absolute. Absolute links are returned as is and anything else is dropped.
+func absoluteURL(base, link string) string {
+	switch {
+	case strings.HasPrefix(link, "http://"), strings.HasPrefix(link, "https://"):
+		return link
+	case strings.HasPrefix(link, "/"):
+		return base + link
+	}
+	return ""
+}
+
+// contentString looks up a string by following path through nested objects
+// and returns def if it is missing or empty
+func contentString(data map[string]interface{}, def string, path ...string) string {
+	var value interface{} = data
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return def
+		}
+		value = m[key]
+	}
+	if s, ok := value.(string); ok && s != "" {
+		return s
+	}
+	return def
+}
+
+// contentList converts a list of objects into template maps. fields maps the
+// Tina field name to the key used in the templates. def is returned if the
+// list is missing or empty.
+func contentList(data map[string]interface{}, key string, fields map[string]string, def []fiber.Map) []fiber.Map {
+	items, ok := data[key].([]interface{})
+	if !ok || len(items) == 0 {
+		return def
+	}
+
+	list := make([]fiber.Map, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entry := fiber.Map{}
+		for field, name := range fields {
+			switch v := obj[field].(type) {
+			case []interface{}:
+				values := make([]string, 0, len(v))
+				for _, s := range v {
+					if str, ok := s.(string); ok {
+						values = append(values, str)
+					}
+				}
+				entry[name] = values
+			case nil:
+				entry[name] = ""
+			default:
+				entry[name] = v
+			}
+		}
+		list = append(list, entry)
+	}
+	if len(list) == 0 {
+		return def
+	}
+	return list
+}
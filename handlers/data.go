@@ -0,0 +1,4 @@
+package handlers
+
+// dataDir holds runtime state that is not content, such as caches and tokens
+const dataDir = "./data"
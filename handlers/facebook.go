@@ -1,157 +1,77 @@
 package handlers
 
 import (
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"os"
-	"sync"
 	"time"
 
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/social"
+
 	"github.com/gofiber/fiber/v2"
 )
 
-type facebookPost struct {
-	ID           string    `json:"id"`
-	Message      string    `json:"message,omitempty"`
-	CreatedTime  time.Time `json:"created_time"`
-	PermalinkURL string    `json:"permalink_url,omitempty"`
-	FullPicture  string    `json:"full_picture,omitempty"`
-}
-
 type facebookFeedResponse struct {
-	Posts          []facebookPost `json:"posts"`
-	Cached         bool           `json:"cached"`
-	Source         string         `json:"source,omitempty"`
-	FallbackReason string         `json:"fallback_reason,omitempty"`
-}
-
-type graphPostsResponse struct {
-	Data []struct {
-		ID           string `json:"id"`
-		Message      string `json:"message"`
-		CreatedTime  string `json:"created_time"`
-		PermalinkURL string `json:"permalink_url"`
-		FullPicture  string `json:"full_picture"`
-	} `json:"data"`
-}
-
-var fbCache struct {
-	mu        sync.Mutex
-	data      facebookFeedResponse
-	expiresAt time.Time
+	Posts          []social.Item `json:"posts"`
+	Cached         bool          `json:"cached"`
+	Stale          bool          `json:"stale"`
+	FetchedAt      *time.Time    `json:"fetched_at,omitempty"`
+	Source         string        `json:"source,omitempty"`
+	FallbackReason string        `json:"fallback_reason,omitempty"`
 }
 
-func FacebookFeed(c *fiber.Ctx) error {
-	// Serve from cache if valid
-	fbCache.mu.Lock()
-	if time.Now().Before(fbCache.expiresAt) {
-		cached := fbCache.data
-		cached.Cached = true
-		fbCache.mu.Unlock()
-		return c.JSON(cached)
-	}
-	fbCache.mu.Unlock()
-
-	pageID := os.Getenv("FACEBOOK_PAGE_ID")
-	if pageID == "" {
-		pageID = os.Getenv("FACEBOOK_PAGE_USERNAME")
-	}
-	if pageID == "" {
-		pageID = "SomamayelRV"
-	}
-
-	accessToken := os.Getenv("FACEBOOK_ACCESS_TOKEN")
-	if accessToken == "" {
-		// Graceful fallback if no token configured
-		resp := facebookFeedResponse{
-			Posts:          []facebookPost{},
-			Cached:         false,
-			Source:         "fallback",
-			FallbackReason: "missing_access_token",
-		}
-		// Short cache to avoid hammering
-		fbCache.mu.Lock()
-		fbCache.data = resp
-		fbCache.expiresAt = time.Now().Add(5 * time.Minute)
-		fbCache.mu.Unlock()
-		return c.JSON(resp)
-	}
-
-	graphVersion := os.Getenv("FACEBOOK_GRAPH_VERSION")
-	if graphVersion == "" {
-		graphVersion = "v18.0"
-	}
-
-	limit := os.Getenv("FACEBOOK_FEED_LIMIT")
-	if limit == "" {
-		limit = "5"
-	}
-
-	// Build Graph API URL
-	fields := "message,created_time,permalink_url,full_picture"
-	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/posts?fields=%s&limit=%s&access_token=%s",
-		graphVersion, pageID, fields, limit, accessToken,
-	)
+// FacebookFeed returns the cached Facebook posts and kicks off a background
+// refresh when they are due. Failed fetches keep the previous posts, which
+// are then reported as stale. /api/social/feed?source=facebook returns the
+// same posts in the merged format.
+func (site *Site) FacebookFeed(c *fiber.Ctx) error {
+	src := site.facebookSource()
+	src.trigger()
+	items, status := src.snapshot(time.Now())
 
-	client := &http.Client{Timeout: 10 * time.Second}
-    res, err := client.Get(url)
-    if err != nil {
-        return apiFallbackWithCache(c, err)
+	resp := facebookFeedResponse{
+		Posts:          items,
+		Cached:         status.Cached,
+		Stale:          status.Stale,
+		FetchedAt:      status.FetchedAt,
+		Source:         "facebook_graph",
+		FallbackReason: status.FallbackReason,
 	}
-	defer res.Body.Close()
-
-    if res.StatusCode < 200 || res.StatusCode >= 300 {
-        return apiFallbackWithCache(c, fmt.Errorf("facebook api status %d", res.StatusCode))
+	if status.FetchedAt == nil {
+		resp.Source = "fallback"
 	}
+	return c.JSON(resp)
+}
 
-	var gp graphPostsResponse
-    if err := json.NewDecoder(res.Body).Decode(&gp); err != nil {
-        return apiFallbackWithCache(c, err)
-	}
+func (site *Site) facebookSource() *socialSource {
+	return site.findSocialSource("facebook")
+}
 
-	posts := make([]facebookPost, 0, len(gp.Data))
-	for _, p := range gp.Data {
-		// Parse created_time (RFC3339)
-		createdAt, err := time.Parse(time.RFC3339, p.CreatedTime)
-		if err != nil {
-			// If parse fails, keep zero time
-		}
-		posts = append(posts, facebookPost{
-			ID:           p.ID,
-			Message:      p.Message,
-			CreatedTime:  createdAt,
-			PermalinkURL: p.PermalinkURL,
-			FullPicture:  p.FullPicture,
-		})
+// resetFacebookRefresh refetches the page's posts straight away, e.g. after
+// the access token changed
+func (site *Site) resetFacebookRefresh() {
+	site.facebookSource().reset()
+	if src := site.findSocialSource("instagram"); src != nil {
+		src.reset()
 	}
+}
 
-	resp := facebookFeedResponse{
-		Posts:  posts,
-		Cached: false,
-		Source: "facebook_graph",
+// facebookGraphClient returns a Graph client with the configured app, using
+// the token saved in the admin UI in place of FACEBOOK_ACCESS_TOKEN
+func (site *Site) facebookGraphClient() *facebook.GraphClient {
+	client := site.newGraphClient()
+	if token, source := site.currentFacebookToken(); source == facebookTokenFromAdmin {
+		client.AccessToken = token
 	}
+	return client
+}
 
-	// Cache result
-	fbCache.mu.Lock()
-	fbCache.data = resp
-	fbCache.expiresAt = time.Now().Add(5 * time.Minute)
-	fbCache.mu.Unlock()
-
-	return c.JSON(resp)
+func (site *Site) facebookClient() facebook.Client {
+	return site.facebookGraphClient()
 }
 
-func apiFallbackWithCache(c *fiber.Ctx, err error) error {
-	resp := facebookFeedResponse{
-		Posts:          []facebookPost{},
-		Cached:         false,
-		Source:         "fallback",
-		FallbackReason: err.Error(),
-	}
-	fbCache.mu.Lock()
-	fbCache.data = resp
-	fbCache.expiresAt = time.Now().Add(2 * time.Minute)
-	fbCache.mu.Unlock()
-    return c.JSON(resp)
+func (site *Site) instagramClient() facebook.InstagramClient {
+	return site.facebookGraphClient()
 }
 
+func (site *Site) facebookPageID() string {
+	return site.cfg.Facebook.PageID
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/jsonfile"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// facebookPublishQueueFile keeps queued cross-posts across restarts
+	facebookPublishQueueFile = dataDir + "/facebook_publish_queue.json"
+	// facebookPublishMaxAttempts is how often a cross-post is tried before
+	// it is marked as failed
+	facebookPublishMaxAttempts = 6
+	// facebookPublishBaseDelay is the wait after the first failure; it
+	// doubles with every further attempt
+	facebookPublishBaseDelay = time.Minute
+
+	facebookStatusQueued    = "queued"
+	facebookStatusPublished = "published"
+	facebookStatusFailed    = "failed"
+)
+
+// facebookPublishJob is a post waiting to be published on the Facebook page
+type facebookPublishJob struct {
+	PostID string `json:"post_id"`
+	// BaseURL is the site's address when the job was queued, used for the
+	// post's link and image if SITE_URL is not set
+	BaseURL     string    `json:"base_url"`
+	Attempts    int       `json:"attempts"`
+	QueuedAt    time.Time `json:"queued_at"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	// Unconfirmed is set when an attempt may have created the post without
+	// its ID reaching us
+	Unconfirmed bool `json:"unconfirmed,omitempty"`
+}
+
+var fbPublish struct {
+	mu     sync.Mutex
+	loaded bool
+	jobs   []facebookPublishJob
+	kick   chan struct{}
+}
+
+// AdminPublishPostToFacebook queues a post for publishing on the Facebook
+// page, e.g. to retry after a failure
+func AdminPublishPostToFacebook(c *fiber.Ctx) error {
+	post := models.GetPostByID(c.Params("id"))
+	if post == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "post not found"})
+	}
+	if post.Draft {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "drafts cannot be published"})
+	}
+	if post.FacebookID != "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "post is already on Facebook"})
+	}
+
+	if err := queueFacebookPublish(post, c.BaseURL()); err != nil {
+		return serverError(c, "failed to queue post", err)
+	}
+	return c.JSON(post)
+}
+
+// StartFacebookPublisher works through queued cross-posts in the background
+// until ctx is cancelled
+func (site *Site) StartFacebookPublisher(ctx context.Context) {
+	fbPublish.mu.Lock()
+	loadFacebookPublishQueueLocked()
+	fbPublish.kick = make(chan struct{}, 1)
+	kick := fbPublish.kick
+	fbPublish.mu.Unlock()
+
+	goWorker(func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			site.processFacebookPublishQueue(ctx)
+			select {
+			case <-ticker.C:
+			case <-kick:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// queueFacebookPublish marks the post as queued, saves it and adds a job
+// for it
+func queueFacebookPublish(post *models.Post, baseURL string) error {
+	post.FacebookStatus = facebookStatusQueued
+	post.FacebookError = ""
+	if err := models.SavePost(post); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	fbPublish.mu.Lock()
+	loadFacebookPublishQueueLocked()
+	jobs := fbPublish.jobs[:0]
+	for _, job := range fbPublish.jobs {
+		if job.PostID != post.ID {
+			jobs = append(jobs, job)
+		}
+	}
+	fbPublish.jobs = append(jobs, facebookPublishJob{
+		PostID:      post.ID,
+		BaseURL:     baseURL,
+		QueuedAt:    now,
+		NextAttempt: now,
+	})
+	err := saveFacebookPublishQueueLocked()
+	kick := fbPublish.kick
+	fbPublish.mu.Unlock()
+
+	if kick != nil {
+		select {
+		case kick <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// processFacebookPublishQueue tries every job that is due, stopping early
+// when ctx is cancelled
+func (site *Site) processFacebookPublishQueue(ctx context.Context) {
+	now := time.Now()
+	fbPublish.mu.Lock()
+	var due []facebookPublishJob
+	for _, job := range fbPublish.jobs {
+		if !now.Before(job.NextAttempt) {
+			due = append(due, job)
+		}
+	}
+	fbPublish.mu.Unlock()
+
+	for _, job := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		keep, updated := site.runFacebookPublishJob(ctx, job)
+
+		fbPublish.mu.Lock()
+		jobs := fbPublish.jobs[:0]
+		for _, j := range fbPublish.jobs {
+			if j.PostID != job.PostID {
+				jobs = append(jobs, j)
+			} else if keep && j.QueuedAt.Equal(job.QueuedAt) {
+				jobs = append(jobs, updated)
+			} else if !j.QueuedAt.Equal(job.QueuedAt) {
+				// Re-queued while we were publishing
+				jobs = append(jobs, j)
+			}
+		}
+		fbPublish.jobs = jobs
+		if err := saveFacebookPublishQueueLocked(); err != nil {
+			slog.Error("facebook: could not save publish queue", "err", err)
+		}
+		fbPublish.mu.Unlock()
+	}
+}
+
+// runFacebookPublishJob publishes the job's post and records the outcome on
+// it. It reports whether the job should stay queued for another attempt,
+// which only temporary failures get. A job cut off by ctx is kept as it
+// was, without counting the attempt.
+func (site *Site) runFacebookPublishJob(ctx context.Context, job facebookPublishJob) (bool, facebookPublishJob) {
+	post := models.GetPostByID(job.PostID)
+	if post == nil || post.FacebookID != "" {
+		return false, job
+	}
+	if post.Draft {
+		recordFacebookPublish(post, facebookStatusFailed, "", "post was changed to a draft")
+		return false, job
+	}
+
+	graph := site.facebookGraphClient()
+	pageID := site.facebookPageID()
+	publication := site.facebookPublication(post, job.BaseURL)
+	publishCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	// Publishing again after an attempt whose answer was lost could post
+	// twice, so look for that attempt's post first
+	var id string
+	var err error
+	if job.Unconfirmed {
+		id, err = graph.FindPublished(publishCtx, pageID, publication, job.QueuedAt)
+		if id != "" {
+			slog.Info("facebook: found post published by an earlier attempt", "post_id", post.ID, "facebook_id", id)
+		}
+	}
+	if err == nil && id == "" {
+		id, err = graph.PublishPost(publishCtx, pageID, publication)
+	}
+	if err == nil {
+		recordFacebookPublish(post, facebookStatusPublished, id, "")
+		slog.Info("facebook: published post", "post_id", post.ID, "facebook_id", id)
+		return false, job
+	}
+	job.Unconfirmed = job.Unconfirmed || publishOutcomeUnknown(err)
+	if ctx.Err() != nil {
+		return true, job
+	}
+
+	reason, delay := socialFailure(err)
+	job.Attempts++
+	job.LastError = reason
+	if !facebook.IsTemporary(err) {
+		recordFacebookPublish(post, facebookStatusFailed, "", reason)
+		slog.Error("facebook: could not publish post", "post_id", post.ID, "err", err)
+		return false, job
+	}
+	if job.Attempts >= facebookPublishMaxAttempts {
+		recordFacebookPublish(post, facebookStatusFailed, "", reason)
+		slog.Error("facebook: giving up publishing post", "post_id", post.ID, "attempts", job.Attempts, "err", err)
+		return false, job
+	}
+
+	backoff := facebookPublishBaseDelay << uint(job.Attempts-1)
+	if backoff < delay {
+		backoff = delay
+	}
+	job.NextAttempt = time.Now().Add(backoff)
+	recordFacebookPublish(post, facebookStatusQueued, "", reason)
+	slog.Warn("facebook: publishing post failed, will retry", "post_id", post.ID, "retry_in", backoff.String(), "err", err)
+	return true, job
+}
+
+// publishOutcomeUnknown reports whether a publish that failed with err may
+// still have created the post: Graph never answered, or failed on its side
+func publishOutcomeUnknown(err error) bool {
+	var graphErr *facebook.Error
+	return !errors.As(err, &graphErr) || graphErr.Status >= 500
+}
+
+// recordFacebookPublish saves the publishing outcome on the latest version
+// of the post
+func recordFacebookPublish(post *models.Post, status, facebookID, reason string) {
+	if latest := models.GetPostByID(post.ID); latest != nil {
+		post = latest
+	}
+	post.FacebookStatus = status
+	post.FacebookError = reason
+	if facebookID != "" {
+		post.FacebookID = facebookID
+		post.FacebookURL = facebookPostURL(facebookID)
+	}
+	if err := models.SavePost(post); err != nil {
+		slog.Error("facebook: could not save publish status", "post_id", post.ID, "err", err)
+	}
+}
+
+// facebookPublication builds what is posted on the page: the title and
+// excerpt, a link to the article and its image
+func (site *Site) facebookPublication(post *models.Post, baseURL string) facebook.Publication {
+	base := site.siteURL(baseURL)
+
+	return facebook.Publication{
+		Message:  strings.TrimSpace(post.Title + "\n\n" + post.Excerpt),
+		Link:     base + "/blog/" + post.Slug,
+		ImageURL: absoluteURL(base, post.Image),
+	}
+}
+
+// facebookPostURL returns the permalink of a "pageID_postID" post ID
+func facebookPostURL(id string) string {
+	if page, post, ok := strings.Cut(id, "_"); ok {
+		return fmt.Sprintf("https://www.facebook.com/%s/posts/%s", page, post)
+	}
+	return "https://www.facebook.com/" + id
+}
+
+func loadFacebookPublishQueueLocked() {
+	if fbPublish.loaded {
+		return
+	}
+	fbPublish.loaded = true
+	if err := jsonfile.Read(facebookPublishQueueFile, &fbPublish.jobs); err != nil && !os.IsNotExist(err) {
+		slog.Warn("facebook: ignoring unreadable publish queue", "file", facebookPublishQueueFile, "err", err)
+	}
+}
+
+func saveFacebookPublishQueueLocked() error {
+	if fbPublish.jobs == nil {
+		fbPublish.jobs = []facebookPublishJob{}
+	}
+	return jsonfile.Write(facebookPublishQueueFile, fbPublish.jobs, 0644)
+}
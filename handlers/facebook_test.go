@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"soma-mayel-campaign/config"
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/facebook/facebooktest"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const testPageID = "1234"
+
+// newFacebookSite returns a Site whose Graph client talks to a fake Graph
+// API, and the fake
+func newFacebookSite(t *testing.T) (*Site, *facebooktest.Server) {
+	t.Helper()
+	srv := facebooktest.NewServer()
+	srv.Token = "page-token"
+	srv.PageID = testPageID
+	t.Cleanup(srv.Close)
+
+	cfg := config.Default()
+	cfg.Server.SiteURL = "https://example.org"
+	cfg.Facebook.GraphURL = srv.URL
+	cfg.Facebook.AccessToken = srv.Token
+	cfg.Facebook.PageID = testPageID
+	return newTestSite(t, cfg), srv
+}
+
+func testPosts(n int) []facebook.Post {
+	newest := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	posts := make([]facebook.Post, n)
+	for i := range posts {
+		posts[i] = facebook.Post{
+			ID:          fmt.Sprintf("%s_%d", testPageID, n-i),
+			Message:     fmt.Sprintf("Post %d", n-i),
+			CreatedTime: newest.Add(-time.Duration(i) * time.Hour),
+		}
+	}
+	return posts
+}
+
+// fetchFacebookFeed asks for the feed, waits for the refresh it starts and
+// asks again
+func fetchFacebookFeed(t *testing.T, site *Site) facebookFeedResponse {
+	t.Helper()
+	app := fiber.New()
+	app.Get("/api/facebook/feed", site.FacebookFeed)
+
+	request(t, app, fiber.MethodGet, "/api/facebook/feed", "", nil)
+	workers.wg.Wait()
+	var resp facebookFeedResponse
+	if status := request(t, app, fiber.MethodGet, "/api/facebook/feed", "", &resp); status != fiber.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	return resp
+}
+
+func TestFacebookFeed(t *testing.T) {
+	tests := []struct {
+		name         string
+		posts        int
+		pageSize     int
+		limit        int
+		token        string
+		fail         *facebook.Error
+		wantPosts    int
+		wantRequests int
+		wantSource   string
+		wantReason   string
+	}{
+		{name: "one page", posts: 3, pageSize: 25, limit: 5, wantPosts: 3, wantRequests: 1, wantSource: "facebook_graph"},
+		{name: "pages until the limit", posts: 7, pageSize: 2, limit: 5, wantPosts: 5, wantRequests: 3, wantSource: "facebook_graph"},
+		{name: "pages until the end", posts: 3, pageSize: 2, limit: 10, wantPosts: 3, wantRequests: 2, wantSource: "facebook_graph"},
+		{name: "no token", posts: 3, pageSize: 25, limit: 5, token: "none", wantRequests: 0, wantSource: "fallback", wantReason: "missing_access_token"},
+		{name: "wrong token", posts: 3, pageSize: 25, limit: 5, token: "someone-else", wantRequests: 1, wantSource: "fallback", wantReason: "token_expired"},
+		{name: "expired token", posts: 3, pageSize: 25, limit: 5, fail: facebooktest.ExpiredToken(), wantRequests: 1, wantSource: "fallback", wantReason: "token_expired"},
+		{name: "rate limited", posts: 3, pageSize: 25, limit: 5, fail: facebooktest.RateLimited(), wantRequests: 1, wantSource: "fallback", wantReason: "rate_limited"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, srv := newFacebookSite(t)
+			srv.SetPosts(testPosts(tt.posts)...)
+			srv.SetPageSize(tt.pageSize)
+			srv.FailWith(tt.fail)
+			site.cfg.Facebook.FeedLimit = tt.limit
+			switch tt.token {
+			case "none":
+				site.cfg.Facebook.AccessToken = ""
+			case "":
+			default:
+				site.cfg.Facebook.AccessToken = tt.token
+			}
+
+			resp := fetchFacebookFeed(t, site)
+			if len(resp.Posts) != tt.wantPosts {
+				t.Errorf("got %d posts, want %d", len(resp.Posts), tt.wantPosts)
+			}
+			if n := srv.Requests(); n != tt.wantRequests {
+				t.Errorf("made %d Graph requests, want %d", n, tt.wantRequests)
+			}
+			if resp.Source != tt.wantSource || resp.FallbackReason != tt.wantReason {
+				t.Errorf("source %q, reason %q, want %q, %q", resp.Source, resp.FallbackReason, tt.wantSource, tt.wantReason)
+			}
+			if tt.wantPosts > 0 && (resp.Posts[0].Message != fmt.Sprintf("Post %d", tt.posts) || resp.Posts[0].Source != "facebook") {
+				t.Errorf("first post is %+v, want the newest", resp.Posts[0])
+			}
+		})
+	}
+}
+
+func TestFacebookFeedKeepsPostsOnFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		fail      *facebook.Error
+		wantRetry time.Duration
+	}{
+		{"expired token", facebooktest.ExpiredToken(), socialRetryDelay},
+		{"rate limited", facebooktest.RateLimited(), socialRateLimitDelay},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, srv := newFacebookSite(t)
+			srv.SetPosts(testPosts(3)...)
+			if resp := fetchFacebookFeed(t, site); len(resp.Posts) != 3 {
+				t.Fatalf("got %d posts before the failure, want 3", len(resp.Posts))
+			}
+
+			srv.FailWith(tt.fail)
+			src := site.facebookSource()
+			src.reset()
+			workers.wg.Wait()
+			requests := srv.Requests()
+
+			items, status := src.snapshot(time.Now().Add(socialFeedTTL + time.Minute))
+			if len(items) != 3 || !status.Stale {
+				t.Errorf("got %d items, stale %v, want the 3 cached items marked stale", len(items), status.Stale)
+			}
+			reason, _ := socialFailure(fmt.Errorf("wrapped: %w", tt.fail))
+			if status.FallbackReason != reason {
+				t.Errorf("reason %q, want %q", status.FallbackReason, reason)
+			}
+
+			// Nothing is fetched again until the retry delay is over
+			src.trigger()
+			workers.wg.Wait()
+			if n := srv.Requests(); n != requests {
+				t.Errorf("fetched again straight after the failure")
+			}
+			src.mu.Lock()
+			wait := time.Until(src.nextAttempt)
+			src.mu.Unlock()
+			if wait < tt.wantRetry-time.Minute || wait > tt.wantRetry {
+				t.Errorf("next attempt in %v, want about %v", wait, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestFacebookPublish(t *testing.T) {
+	permissionDenied := &facebook.Error{Status: 403, Message: "(#200) Requires pages_manage_posts permission", Type: "OAuthException", Code: facebook.CodePermissionDenied}
+	unavailable := &facebook.Error{Status: 503, Message: "Service temporarily unavailable", Code: facebook.CodeAPIService, Transient: true}
+	tests := []struct {
+		name         string
+		fail         *facebook.Error
+		attempts     int // made before this one
+		wantStatus   string
+		wantError    string
+		wantQueued   bool
+		wantAttempts int
+		wantRetry    time.Duration
+	}{
+		{name: "published", wantStatus: facebookStatusPublished},
+		{name: "expired token", fail: facebooktest.ExpiredToken(), wantStatus: facebookStatusFailed, wantError: "token_expired"},
+		{name: "permission denied", fail: permissionDenied, wantStatus: facebookStatusFailed, wantError: permissionDenied.Error()},
+		{name: "rate limited", fail: facebooktest.RateLimited(), wantStatus: facebookStatusQueued, wantError: "rate_limited", wantQueued: true, wantAttempts: 1, wantRetry: socialRateLimitDelay},
+		{name: "unavailable", fail: unavailable, wantStatus: facebookStatusQueued, wantError: unavailable.Error(), wantQueued: true, wantAttempts: 1, wantRetry: facebookPublishBaseDelay * 2},
+		{name: "backs off", fail: unavailable, attempts: 3, wantStatus: facebookStatusQueued, wantError: unavailable.Error(), wantQueued: true, wantAttempts: 4, wantRetry: facebookPublishBaseDelay * 8},
+		{name: "gives up", fail: unavailable, attempts: facebookPublishMaxAttempts - 1, wantStatus: facebookStatusFailed, wantError: unavailable.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, srv := newFacebookSite(t)
+			srv.FailWith(tt.fail)
+
+			post := &models.Post{ID: "valg", Slug: "valg", Title: "Valg", Excerpt: "Stem", Image: "/static/images/valg.jpg", Date: time.Now()}
+			if err := models.SavePost(post); err != nil {
+				t.Fatal(err)
+			}
+			if err := queueFacebookPublish(post, "http://localhost:3000"); err != nil {
+				t.Fatal(err)
+			}
+			fbPublish.mu.Lock()
+			fbPublish.jobs[0].Attempts = tt.attempts
+			fbPublish.mu.Unlock()
+
+			site.processFacebookPublishQueue(context.Background())
+
+			saved := models.GetPostByID(post.ID)
+			if saved.FacebookStatus != tt.wantStatus || saved.FacebookError != tt.wantError {
+				t.Errorf("post status %q, error %q, want %q, %q", saved.FacebookStatus, saved.FacebookError, tt.wantStatus, tt.wantError)
+			}
+
+			fbPublish.mu.Lock()
+			jobs := append([]facebookPublishJob(nil), fbPublish.jobs...)
+			fbPublish.mu.Unlock()
+			if queued := len(jobs) > 0; queued != tt.wantQueued {
+				t.Fatalf("queued %v, want %v", queued, tt.wantQueued)
+			}
+			if tt.wantQueued {
+				wait := time.Until(jobs[0].NextAttempt)
+				if jobs[0].Attempts != tt.wantAttempts || wait < tt.wantRetry-time.Minute || wait > tt.wantRetry {
+					t.Errorf("attempt %d, next in %v, want attempt %d in about %v", jobs[0].Attempts, wait, tt.wantAttempts, tt.wantRetry)
+				}
+			}
+
+			if tt.wantStatus != facebookStatusPublished {
+				return
+			}
+			if saved.FacebookID != testPageID+"_1" || saved.FacebookURL != "https://www.facebook.com/"+testPageID+"/posts/1" {
+				t.Errorf("facebook id %q, url %q", saved.FacebookID, saved.FacebookURL)
+			}
+			// Posts with an image go up as a photo with the link in the caption
+			want := facebook.Publication{Message: "Valg\n\nStem\n\nhttps://example.org/blog/valg", ImageURL: "https://example.org/static/images/valg.jpg"}
+			if got := srv.Published(); len(got) != 1 || got[0] != want {
+				t.Errorf("published %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFacebookPublishAfterLostReply(t *testing.T) {
+	site, srv := newFacebookSite(t)
+
+	post := &models.Post{ID: "valg", Slug: "valg", Title: "Valg", Excerpt: "Stem", Date: time.Now()}
+	if err := models.SavePost(post); err != nil {
+		t.Fatal(err)
+	}
+	if err := queueFacebookPublish(post, "http://localhost:3000"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Facebook creates the post but the answer is a server error
+	srv.LoseNextReply(&facebook.Error{Status: 500, Message: "An unknown error has occurred.", Code: facebook.CodeAPIUnknown})
+	site.processFacebookPublishQueue(context.Background())
+	fbPublish.mu.Lock()
+	if len(fbPublish.jobs) != 1 || !fbPublish.jobs[0].Unconfirmed {
+		t.Fatalf("jobs %+v, want one unconfirmed job", fbPublish.jobs)
+	}
+	fbPublish.jobs[0].NextAttempt = time.Now()
+	fbPublish.mu.Unlock()
+
+	site.processFacebookPublishQueue(context.Background())
+	if got := srv.Published(); len(got) != 1 {
+		t.Errorf("published %d times, want once: %+v", len(got), got)
+	}
+	saved := models.GetPostByID(post.ID)
+	if saved.FacebookStatus != facebookStatusPublished || saved.FacebookID != testPageID+"_1" {
+		t.Errorf("post status %q, facebook id %q, want %q, %q", saved.FacebookStatus, saved.FacebookID, facebookStatusPublished, testPageID+"_1")
+	}
+	fbPublish.mu.Lock()
+	defer fbPublish.mu.Unlock()
+	if len(fbPublish.jobs) != 0 {
+		t.Errorf("jobs %+v left in the queue", fbPublish.jobs)
+	}
+}
+
+func TestAdminSaveFacebookTokenExchange(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		pageID     string
+		appSecret  string
+		wantStatus int
+		wantError  string
+		wantToken  string
+	}{
+		{"plain token", `{"access_token":" page-token "}`, testPageID, "secret", fiber.StatusOK, "", "page-token"},
+		{"exchange", `{"access_token":"short","exchange":true}`, testPageID, "secret", fiber.StatusOK, "", "page-long-lived-short"},
+		{"exchange for an unmanaged page", `{"access_token":"short","exchange":true}`, "9999", "secret", fiber.StatusBadGateway, "page_not_managed", ""},
+		{"exchange without a page", `{"access_token":"short","exchange":true}`, "", "secret", fiber.StatusBadRequest, "FACEBOOK_PAGE_ID is not set", ""},
+		{"exchange with the wrong app secret", `{"access_token":"short","exchange":true}`, testPageID, "wrong", fiber.StatusBadGateway, "facebook api status 400: (#1) Error validating client secret.", ""},
+		{"no token", `{"access_token":""}`, testPageID, "secret", fiber.StatusBadRequest, "access_token is required", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, srv := newFacebookSite(t)
+			srv.Token = ""
+			srv.AppID, srv.AppSecret = "app", "secret"
+			site.cfg.Facebook.AppID, site.cfg.Facebook.AppSecret = "app", tt.appSecret
+			site.cfg.Facebook.PageID = tt.pageID
+
+			app := fiber.New()
+			app.Post("/api/admin/facebook/token", site.AdminSaveFacebookToken)
+			var resp struct {
+				Error  string `json:"error"`
+				Source string `json:"source"`
+				Type   string `json:"type"`
+			}
+			status := request(t, app, fiber.MethodPost, "/api/admin/facebook/token", tt.body, &resp)
+			if status != tt.wantStatus || resp.Error != tt.wantError {
+				t.Fatalf("got %d %q, want %d %q", status, resp.Error, tt.wantStatus, tt.wantError)
+			}
+
+			token, source := site.currentFacebookToken()
+			if tt.wantToken == "" {
+				if source == facebookTokenFromAdmin {
+					t.Errorf("stored %q after a failure", token)
+				}
+				return
+			}
+			if token != tt.wantToken || source != facebookTokenFromAdmin {
+				t.Errorf("token %q from %q, want %q from the admin UI", token, source, tt.wantToken)
+			}
+			if tt.name == "exchange" && resp.Type != "PAGE" {
+				t.Errorf("stored token is a %q token, want PAGE", resp.Type)
+			}
+		})
+	}
+}
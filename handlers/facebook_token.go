@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/jsonfile"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// facebookTokenFile stores the token saved in the admin UI
+	facebookTokenFile = dataDir + "/facebook_token.json"
+	// facebookTokenCheckInterval is how often the token is inspected
+	facebookTokenCheckInterval = 24 * time.Hour
+
+	facebookTokenFromAdmin = "admin"
+	facebookTokenFromEnv   = "env"
+)
+
+// storedFacebookToken is the admin-managed token and the last inspection of
+// whichever token is in use
+type storedFacebookToken struct {
+	AccessToken string              `json:"access_token,omitempty"`
+	UpdatedAt   time.Time           `json:"updated_at,omitempty"`
+	Hint        string              `json:"hint,omitempty"`
+	Info        *facebook.TokenInfo `json:"info,omitempty"`
+	CheckedAt   time.Time           `json:"checked_at,omitempty"`
+}
+
+var fbToken struct {
+	mu       sync.Mutex
+	loaded   bool
+	stored   storedFacebookToken
+	checking bool
+}
+
+// facebookTokenStatus is what the admin UI shows about the token
+type facebookTokenStatus struct {
+	Configured          bool       `json:"configured"`
+	Source              string     `json:"source,omitempty"`
+	Hint                string     `json:"hint,omitempty"`
+	Valid               *bool      `json:"valid,omitempty"`
+	Type                string     `json:"type,omitempty"`
+	ExpiresAt           *time.Time `json:"expires_at,omitempty"`
+	NeverExpires        bool       `json:"never_expires"`
+	DaysLeft            *int       `json:"days_left,omitempty"`
+	DataAccessExpiresAt *time.Time `json:"data_access_expires_at,omitempty"`
+	Scopes              []string   `json:"scopes,omitempty"`
+	CheckedAt           *time.Time `json:"checked_at,omitempty"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
+	Exchange            bool       `json:"exchange_available"`
+
+	// Warning is one of missing, unchecked, invalid, expired or
+	// expiring_soon, and empty when all is well
+	Warning string `json:"warning,omitempty"`
+}
+
+// AdminFacebookTokenStatus reports which token is in use and when it expires
+func (site *Site) AdminFacebookTokenStatus(c *fiber.Ctx) error {
+	return c.JSON(site.currentFacebookTokenStatus(time.Now()))
+}
+
+type saveFacebookTokenRequest struct {
+	AccessToken string `json:"access_token"`
+	Exchange    bool   `json:"exchange"`
+}
+
+// AdminSaveFacebookToken stores a new access token. With exchange set, the
+// token is a short-lived user token: it is traded for a long-lived one, and
+// that for the page's own token, which does not expire and is what gets
+// stored.
+func (site *Site) AdminSaveFacebookToken(c *fiber.Ctx) error {
+	var req saveFacebookTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payload"})
+	}
+	token := strings.TrimSpace(req.AccessToken)
+	if token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "access_token is required"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if req.Exchange {
+		pageID := site.facebookPageID()
+		if pageID == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "FACEBOOK_PAGE_ID is not set"})
+		}
+		graph := site.facebookGraphClient()
+		long, err := graph.ExchangeToken(ctx, token)
+		if err == nil {
+			token, err = graph.PageToken(ctx, long.AccessToken, pageID)
+		}
+		if err != nil {
+			reason, _ := socialFailure(err)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": reason})
+		}
+	}
+
+	fbToken.mu.Lock()
+	loadFacebookTokenLocked()
+	fbToken.stored = storedFacebookToken{AccessToken: token, UpdatedAt: time.Now()}
+	err := jsonfile.Write(facebookTokenFile, fbToken.stored, 0600)
+	fbToken.mu.Unlock()
+	if err != nil {
+		return serverError(c, "failed to save token", err)
+	}
+
+	// Inspect the new token straight away so the expiry is known
+	if _, err := site.checkFacebookToken(ctx); err != nil {
+		requestLog(c).Warn("facebook: could not inspect new token", "err", err)
+	}
+	site.resetFacebookRefresh()
+
+	return c.JSON(site.currentFacebookTokenStatus(time.Now()))
+}
+
+// AdminDeleteFacebookToken removes the admin token, falling back to
+// FACEBOOK_ACCESS_TOKEN
+func (site *Site) AdminDeleteFacebookToken(c *fiber.Ctx) error {
+	fbToken.mu.Lock()
+	fbToken.loaded = true
+	fbToken.stored = storedFacebookToken{}
+	err := os.Remove(facebookTokenFile)
+	fbToken.mu.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return serverError(c, "failed to delete token", err)
+	}
+
+	site.resetFacebookRefresh()
+	return c.JSON(site.currentFacebookTokenStatus(time.Now()))
+}
+
+// AdminDebugFacebookToken inspects the token in use via Graph's debug_token
+func (site *Site) AdminDebugFacebookToken(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	info, err := site.checkFacebookToken(ctx)
+	if err != nil {
+		reason, _ := socialFailure(err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": reason})
+	}
+	return c.JSON(fiber.Map{
+		"info":   info,
+		"status": site.currentFacebookTokenStatus(time.Now()),
+	})
+}
+
+// currentFacebookToken returns the token to use and where it came from. A
+// token saved in the admin UI wins over FACEBOOK_ACCESS_TOKEN.
+func (site *Site) currentFacebookToken() (string, string) {
+	fbToken.mu.Lock()
+	loadFacebookTokenLocked()
+	token := fbToken.stored.AccessToken
+	fbToken.mu.Unlock()
+
+	if token != "" {
+		return token, facebookTokenFromAdmin
+	}
+	if token := site.cfg.Facebook.AccessToken; token != "" {
+		return token, facebookTokenFromEnv
+	}
+	return "", ""
+}
+
+func loadFacebookTokenLocked() {
+	if fbToken.loaded {
+		return
+	}
+	fbToken.loaded = true
+	if err := jsonfile.Read(facebookTokenFile, &fbToken.stored); err != nil && !os.IsNotExist(err) {
+		slog.Warn("facebook: ignoring unreadable token file", "file", facebookTokenFile, "err", err)
+	}
+}
+
+// checkFacebookToken inspects the token in use and records the result
+func (site *Site) checkFacebookToken(ctx context.Context) (*facebook.TokenInfo, error) {
+	token, _ := site.currentFacebookToken()
+	if token == "" {
+		return nil, facebook.ErrNoAccessToken
+	}
+	info, err := site.facebookGraphClient().DebugToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	fbToken.mu.Lock()
+	defer fbToken.mu.Unlock()
+	fbToken.stored.Hint = tokenHint(token)
+	fbToken.stored.Info = info
+	fbToken.stored.CheckedAt = time.Now()
+	if err := jsonfile.Write(facebookTokenFile, fbToken.stored, 0600); err != nil {
+		slog.Error("facebook: could not save token info", "err", err)
+	}
+	return info, nil
+}
+
+// triggerFacebookTokenCheck inspects the token in the background once a day
+// so the admin warning appears in good time before it expires
+func (site *Site) triggerFacebookTokenCheck() {
+	token, _ := site.currentFacebookToken()
+	if token == "" {
+		return
+	}
+
+	fbToken.mu.Lock()
+	due := fbToken.stored.Hint != tokenHint(token) ||
+		time.Since(fbToken.stored.CheckedAt) > facebookTokenCheckInterval
+	if fbToken.checking || !due {
+		fbToken.mu.Unlock()
+		return
+	}
+	fbToken.checking = true
+	fbToken.mu.Unlock()
+
+	started := goWorker(func() {
+		ctx, cancel := context.WithTimeout(shutdownContext(), 30*time.Second)
+		defer cancel()
+		info, err := site.checkFacebookToken(ctx)
+
+		fbToken.mu.Lock()
+		fbToken.checking = false
+		if err != nil {
+			// Try again at the next check instead of waiting a day
+			fbToken.stored.CheckedAt = time.Now().Add(-facebookTokenCheckInterval + socialRetryDelay)
+		}
+		fbToken.mu.Unlock()
+
+		switch {
+		case err != nil:
+			slog.Warn("facebook: token check failed", "err", err)
+		case !info.IsValid:
+			slog.Warn("facebook: access token is no longer valid")
+		case !info.ExpiresAt.IsZero() && time.Until(info.ExpiresAt) < site.facebookTokenWarnPeriod():
+			slog.Warn("facebook: access token expires soon", "expires_at", info.ExpiresAt)
+		}
+	})
+	if !started {
+		fbToken.mu.Lock()
+		fbToken.checking = false
+		fbToken.mu.Unlock()
+	}
+}
+
+func (site *Site) currentFacebookTokenStatus(now time.Time) facebookTokenStatus {
+	token, source := site.currentFacebookToken()
+	graph := site.newGraphClient()
+	status := facebookTokenStatus{
+		Configured: token != "",
+		Source:     source,
+		Exchange:   graph.AppID != "" && graph.AppSecret != "" && site.facebookPageID() != "",
+	}
+	if token == "" {
+		status.Warning = "missing"
+		return status
+	}
+	status.Hint = tokenHint(token)
+
+	fbToken.mu.Lock()
+	stored := fbToken.stored
+	fbToken.mu.Unlock()
+
+	if source == facebookTokenFromAdmin && !stored.UpdatedAt.IsZero() {
+		updated := stored.UpdatedAt
+		status.UpdatedAt = &updated
+	}
+
+	info := stored.Info
+	if info == nil || stored.Hint != status.Hint {
+		status.Warning = "unchecked"
+	} else {
+		valid := info.IsValid
+		checked := stored.CheckedAt
+		status.Valid = &valid
+		status.Type = info.Type
+		status.Scopes = info.Scopes
+		status.CheckedAt = &checked
+		status.NeverExpires = info.NeverExpires()
+		if !info.DataAccessExpiresAt.IsZero() {
+			dataAccess := info.DataAccessExpiresAt
+			status.DataAccessExpiresAt = &dataAccess
+		}
+
+		switch {
+		case !info.IsValid:
+			status.Warning = "invalid"
+		case !info.ExpiresAt.IsZero():
+			expires := info.ExpiresAt
+			days := int(expires.Sub(now).Hours() / 24)
+			status.ExpiresAt = &expires
+			status.DaysLeft = &days
+			if !now.Before(expires) {
+				status.Warning = "expired"
+			} else if expires.Sub(now) < site.facebookTokenWarnPeriod() {
+				status.Warning = "expiring_soon"
+			}
+		}
+	}
+
+	// The feed notices an expired token before the daily check does
+	if site.facebookSource().lastError() == "token_expired" {
+		status.Warning = "expired"
+	}
+
+	return status
+}
+
+// facebookTokenWarnPeriod is how long before expiry the admin is warned,
+// from FACEBOOK_TOKEN_WARN_DAYS (default 14)
+func (site *Site) facebookTokenWarnPeriod() time.Duration {
+	return time.Duration(site.cfg.Facebook.TokenWarnDays) * 24 * time.Hour
+}
+
+// tokenHint identifies a token without revealing it
+func tokenHint(token string) string {
+	if len(token) <= 6 {
+		return "…"
+	}
+	return "…" + token[len(token)-6:]
+}
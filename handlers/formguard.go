@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/i18n"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// formTokenField carries the signed time the form was rendered
+	formTokenField = "form_token"
+	// formHoneypotField is hidden from people, so only bots fill it in
+	formHoneypotField = "website"
+	// formTokenTTL is how long a rendered form can be submitted
+	formTokenTTL = 24 * time.Hour
+
+	formIPWindow    = 10 * time.Minute
+	formEmailWindow = time.Hour
+
+	// Reasons a submission is held back
+	formRejectHoneypot    = "honeypot"
+	formRejectToken       = "invalid_token"
+	formRejectTooFast     = "too_fast"
+	formRejectRateLimited = "rate_limited"
+	formRejectSpam        = "spam"
+)
+
+// defaultSpamWords are scored in every submission, in addition to the
+// comma-separated FORM_BLOCKLIST
+var defaultSpamWords = []string{"viagra", "cialis", "casino", "crypto", "bitcoin", "forex", "backlinks", "seo services", "porn", "loan offer"}
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)`)
+
+// protectedForm is how a form answers a held-back submission and how a
+// quarantined submission is processed once staff let it through
+type protectedForm struct {
+	reject  func(c *fiber.Ctx, fields map[string]string, reason string) error
+	release func(logger *slog.Logger, q models.QuarantinedSubmission) error
+}
+
+func (site *Site) protectedForms() map[string]protectedForm {
+	return map[string]protectedForm{
+		"newsletter": {reject: rejectNewsletterSubmission, release: site.releaseNewsletterSubmission},
+	}
+}
+
+var formLimits struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+// FormToken returns the value of a form's hidden form_token field. It
+// records when the form was rendered, so submissions that come back faster
+// than a person could fill the form in are caught.
+func (site *Site) FormToken(form string) string {
+	return site.signValue("form:"+form, strconv.FormatInt(time.Now().Unix(), 10), time.Now().Add(formTokenTTL))
+}
+
+// ProtectForm returns middleware that screens submissions of a public form
+// with a honeypot field, a minimum time to submit, per-IP and per-e-mail
+// rate limits and a content score. Rate-limited visitors are told to try
+// again later; other held-back submissions are quarantined for review.
+func (site *Site) ProtectForm(form string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fields := formFields(c)
+		reason, score := site.screenSubmission(form, fields, c.IP(), time.Now())
+		if reason == "" {
+			return c.Next()
+		}
+
+		requestLog(c).Warn("forms: held back submission", "form", form, "ip", c.IP(), "reason", reason, "score", score)
+		if reason != formRejectRateLimited {
+			delete(fields, formTokenField)
+			q := &models.QuarantinedSubmission{
+				Form:      form,
+				Reason:    reason,
+				Score:     score,
+				Fields:    fields,
+				Email:     models.NormalizeEmail(fields["email"]),
+				IP:        c.IP(),
+				UserAgent: c.Get(fiber.HeaderUserAgent),
+				BaseURL:   c.BaseURL(),
+				CreatedAt: time.Now(),
+			}
+			if err := models.SaveQuarantined(q); err != nil {
+				requestLog(c).Error("forms: could not quarantine submission", "form", form, "err", err)
+			}
+		}
+		return site.protectedForms()[form].reject(c, fields, reason)
+	}
+}
+
+// AdminListQuarantine returns the held-back form submissions
+func AdminListQuarantine(c *fiber.Ctx) error {
+	submissions := models.GetAllQuarantined()
+	if submissions == nil {
+		submissions = []models.QuarantinedSubmission{}
+	}
+	return c.JSON(submissions)
+}
+
+// AdminReleaseQuarantined processes a held-back submission as if it had
+// passed the checks and removes it from quarantine
+func (site *Site) AdminReleaseQuarantined(c *fiber.Ctx) error {
+	q := models.GetQuarantinedByID(c.Params("id"))
+	if q == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "submission not found"})
+	}
+	form, ok := site.protectedForms()[q.Form]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown form " + q.Form})
+	}
+	if err := form.release(requestLog(c), *q); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := models.DeleteQuarantined(q.ID); err != nil {
+		requestLog(c).Error("forms: could not remove released submission", "submission_id", q.ID, "err", err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AdminDeleteQuarantined discards a held-back submission
+func AdminDeleteQuarantined(c *fiber.Ctx) error {
+	if err := models.DeleteQuarantined(c.Params("id")); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "submission not found"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// screenSubmission returns why a submission should be held back, or "" if
+// it looks like it came from a person, and its content score
+func (site *Site) screenSubmission(form string, fields map[string]string, ip string, now time.Time) (string, int) {
+	if strings.TrimSpace(fields[formHoneypotField]) != "" {
+		return formRejectHoneypot, 0
+	}
+
+	issued, err := site.verifySigned("form:"+form, fields[formTokenField])
+	if err != nil {
+		return formRejectToken, 0
+	}
+	at, err := strconv.ParseInt(issued, 10, 64)
+	if err != nil {
+		return formRejectToken, 0
+	}
+	if now.Sub(time.Unix(at, 0)) < time.Duration(site.cfg.Forms.MinSeconds)*time.Second {
+		return formRejectTooFast, 0
+	}
+
+	if !allowSubmission(form+"|ip|"+ip, site.cfg.Forms.IPLimit, formIPWindow, now) {
+		return formRejectRateLimited, 0
+	}
+	if email := models.NormalizeEmail(fields["email"]); email != "" {
+		if !allowSubmission(form+"|email|"+email, site.cfg.Forms.EmailLimit, formEmailWindow, now) {
+			return formRejectRateLimited, 0
+		}
+	}
+
+	if score := site.spamScore(fields); score >= site.cfg.Forms.SpamScore {
+		return formRejectSpam, score
+	}
+	return "", 0
+}
+
+// spamScore adds two points per link, five for markup links or a link in
+// a name, and three per blocklisted word
+func (site *Site) spamScore(fields map[string]string) int {
+	words := defaultSpamWords
+	for _, w := range site.cfg.Forms.Blocklist {
+		words = append(words, strings.ToLower(w))
+	}
+
+	score := 0
+	for name, value := range fields {
+		if name == formTokenField {
+			continue
+		}
+		lower := strings.ToLower(value)
+		links := len(linkPattern.FindAllStringIndex(lower, -1))
+		score += 2 * links
+		if name == "name" && links > 0 {
+			score += 5
+		}
+		if strings.Contains(lower, "[url") || strings.Contains(lower, "<a href") {
+			score += 5
+		}
+		for _, w := range words {
+			if strings.Contains(lower, w) {
+				score += 3
+			}
+		}
+	}
+	return score
+}
+
+// allowSubmission counts a submission for key and reports whether fewer
+// than limit were made within window
+func allowSubmission(key string, limit int, window time.Duration, now time.Time) bool {
+	formLimits.mu.Lock()
+	defer formLimits.mu.Unlock()
+
+	if formLimits.hits == nil {
+		formLimits.hits = map[string][]time.Time{}
+	}
+	// Forget idle keys now and then so the map does not grow forever
+	if len(formLimits.hits) > 10000 {
+		for k, hits := range formLimits.hits {
+			if now.Sub(hits[len(hits)-1]) > formEmailWindow {
+				delete(formLimits.hits, k)
+			}
+		}
+	}
+
+	var recent []time.Time
+	for _, t := range formLimits.hits[key] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	allowed := len(recent) < limit
+	if allowed {
+		recent = append(recent, now)
+	}
+	formLimits.hits[key] = recent
+	return allowed
+}
+
+// formFields returns the submitted fields of a form post or JSON body
+func formFields(c *fiber.Ctx) map[string]string {
+	fields := map[string]string{}
+	if c.Is("json") {
+		var raw map[string]interface{}
+		if err := json.Unmarshal(c.Body(), &raw); err == nil {
+			for k, v := range raw {
+				if v != nil {
+					fields[k] = fmt.Sprint(v)
+				}
+			}
+		}
+		return fields
+	}
+	c.Request().PostArgs().VisitAll(func(k, v []byte) {
+		fields[string(k)] = string(v)
+	})
+	if form, err := c.MultipartForm(); err == nil {
+		for k, v := range form.Value {
+			if len(v) > 0 {
+				fields[k] = v[0]
+			}
+		}
+	}
+	return fields
+}
+
+// formLocale returns the locale a form was filled in, from its hidden
+// locale field or the request
+func formLocale(c *fiber.Ctx, fields map[string]string) string {
+	if _, ok := i18n.Get(fields["locale"]); ok {
+		return fields["locale"]
+	}
+	return i18n.FromCtx(c)
+}
+
+// rejectionStatus is what a visitor is told about a held-back submission.
+// Bots that tripped the honeypot or content score are told it went
+// through, so they do not learn to avoid the checks.
+func rejectionStatus(reason, success string) (string, int) {
+	switch reason {
+	case formRejectRateLimited:
+		return "rate_limited", fiber.StatusTooManyRequests
+	case formRejectToken, formRejectTooFast:
+		return "retry", fiber.StatusBadRequest
+	}
+	return success, fiber.StatusOK
+}
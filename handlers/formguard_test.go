@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"soma-mayel-campaign/config"
+)
+
+func TestSpamScore(t *testing.T) {
+	cfg := config.Default()
+	cfg.Forms.Blocklist = []string{"Miracle Cure"}
+	site := newTestSite(t, cfg)
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   int
+	}{
+		{"plain message", map[string]string{"name": "Sara", "email": "sara@example.org", "message": "Hvornår er næste møde?"}, 0},
+		{"one link", map[string]string{"message": "Se https://example.org"}, 2},
+		{"two links", map[string]string{"message": "http://a.example og www.b.example"}, 4},
+		{"link in name", map[string]string{"name": "www.example.org"}, 2 + 5},
+		{"markup link", map[string]string{"message": `<a href="https://example.org">x</a>`}, 2 + 5},
+		{"bbcode link", map[string]string{"message": "[url=https://example.org]x[/url]"}, 2 + 5},
+		{"spam word", map[string]string{"message": "Cheap CASINO bonus"}, 3},
+		{"two spam words", map[string]string{"message": "crypto and forex"}, 6},
+		{"blocklisted phrase", map[string]string{"message": "a miracle cure"}, 3},
+		{"every field counts", map[string]string{"name": "casino", "message": "casino"}, 6},
+		{"form token is ignored", map[string]string{formTokenField: "https://casino.example"}, 0},
+	}
+	for _, tt := range tests {
+		if got := site.spamScore(tt.fields); got != tt.want {
+			t.Errorf("%s: spamScore = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScreenSubmission(t *testing.T) {
+	cfg := config.Default()
+	cfg.Forms.MinSeconds = 3
+	cfg.Forms.IPLimit = 2
+	cfg.Forms.SpamScore = 5
+	site := newTestSite(t, cfg)
+
+	now := time.Now()
+	token := func(age time.Duration) string {
+		return site.signValue("form:newsletter", strconv.FormatInt(now.Add(-age).Unix(), 10), now.Add(formTokenTTL))
+	}
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		ip     string
+		want   string
+	}{
+		{"person", map[string]string{formTokenField: token(time.Minute), "email": "a@example.org"}, "10.0.0.1", ""},
+		{"honeypot", map[string]string{formTokenField: token(time.Minute), formHoneypotField: "x"}, "10.0.0.2", formRejectHoneypot},
+		{"no token", map[string]string{"email": "b@example.org"}, "10.0.0.3", formRejectToken},
+		{"token for another form", map[string]string{formTokenField: site.FormToken("other")}, "10.0.0.3", formRejectToken},
+		{"too fast", map[string]string{formTokenField: token(time.Second)}, "10.0.0.4", formRejectTooFast},
+		{"spam", map[string]string{formTokenField: token(time.Minute), "message": "casino https://x.example"}, "10.0.0.5", formRejectSpam},
+		{"second from one address", map[string]string{formTokenField: token(time.Minute)}, "10.0.0.1", ""},
+		{"third from one address", map[string]string{formTokenField: token(time.Minute)}, "10.0.0.1", formRejectRateLimited},
+	}
+	for _, tt := range tests {
+		if got, _ := site.screenSubmission("newsletter", tt.fields, tt.ip, now); got != tt.want {
+			t.Errorf("%s: screenSubmission = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"soma-mayel-campaign/config"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// newTestSite returns a Site for cfg, or the development defaults when cfg
+// is nil, working in an empty directory that is removed after the test
+func newTestSite(t *testing.T, cfg *config.Config) *Site {
+	t.Helper()
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	if cfg.Server.AppSecret == "" {
+		cfg.Server.AppSecret = "test-secret"
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	resetState()
+	t.Cleanup(func() {
+		// Background fetches write to the data directory
+		workers.wg.Wait()
+		resetState()
+		os.Chdir(wd)
+	})
+	return New(cfg, nil)
+}
+
+// resetState forgets what the package loaded from the data directory
+func resetState() {
+	fbToken.mu.Lock()
+	fbToken.loaded = false
+	fbToken.stored = storedFacebookToken{}
+	fbToken.mu.Unlock()
+
+	fbPublish.mu.Lock()
+	fbPublish.loaded = false
+	fbPublish.jobs = nil
+	fbPublish.mu.Unlock()
+
+	formLimits.mu.Lock()
+	formLimits.hits = nil
+	formLimits.mu.Unlock()
+
+	pageViews.mu.Lock()
+	pageViews.days = nil
+	pageViews.mu.Unlock()
+
+	workers.mu.Lock()
+	workers.ctx = nil
+	workers.stopped = false
+	workers.mu.Unlock()
+}
+
+// request sends a request to app and decodes the JSON response into v,
+// returning the status code
+func request(t *testing.T, app *fiber.App, method, path, body string, v interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("%s %s: %v in %s", method, path, err, data)
+		}
+	}
+	return resp.StatusCode
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// readyCheckTimeout bounds each readiness check
+const readyCheckTimeout = 3 * time.Second
+
+// readyCheck is one dependency checked by /readyz. Optional checks only run
+// when named in READY_CHECKS, since an outage elsewhere should not take the
+// site out of a load balancer unless that is wanted.
+type readyCheck struct {
+	name     string
+	optional bool
+	run      func(ctx context.Context) (string, error)
+}
+
+func (site *Site) readyChecks() []readyCheck {
+	return []readyCheck{
+		{name: "content", run: site.checkContentDir},
+		{name: "templates", run: site.checkTemplates},
+		{name: "posts", run: site.checkPosts},
+		{name: "smtp", optional: true, run: site.checkSMTP},
+		{name: "facebook_graph", optional: true, run: site.checkGraphAPI},
+	}
+}
+
+// readyCheckResult is the outcome of one check, as shown to staff by
+// /api/admin/health. /readyz only shows the status.
+type readyCheckResult struct {
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Healthz answers as long as the process serves requests
+func Healthz(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// Readyz runs the readiness checks and answers 503 if any of them fails.
+// It needs no login, so it only names each check with its status; failures
+// are logged, and /api/admin/health shows the details.
+func (site *Site) Readyz(c *fiber.Ctx) error {
+	status, results := site.runReadyChecks()
+	checks := make(map[string]string, len(results))
+	for name, r := range results {
+		checks[name] = r.Status
+		if r.Status == "fail" {
+			requestLog(c).Warn("readyz: check failed", "check", name, "err", r.Error)
+		}
+	}
+	return c.Status(readyStatusCode(status)).JSON(fiber.Map{"status": status, "checks": checks})
+}
+
+// AdminHealth runs the readiness checks like /readyz and reports the
+// detail, error and duration of each
+func (site *Site) AdminHealth(c *fiber.Ctx) error {
+	status, results := site.runReadyChecks()
+	return c.Status(readyStatusCode(status)).JSON(fiber.Map{"status": status, "checks": results})
+}
+
+// runReadyChecks runs the readiness checks in parallel. The status is
+// "fail" if any of them fails; skipped optional checks do not count.
+func (site *Site) runReadyChecks() (string, map[string]readyCheckResult) {
+	enabled := map[string]bool{}
+	for _, name := range site.cfg.Server.ReadyChecks {
+		enabled[name] = true
+	}
+
+	checks := site.readyChecks()
+	results := make(map[string]readyCheckResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		if check.optional && !enabled[check.name] {
+			// Checks started earlier in the loop may be writing already
+			mu.Lock()
+			results[check.name] = readyCheckResult{Status: "skipped"}
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(check readyCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), readyCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			detail, err := check.run(ctx)
+			result := readyCheckResult{Status: "ok", Detail: detail, DurationMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			results[check.name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	status := "ok"
+	for _, r := range results {
+		if r.Status == "fail" {
+			status = "fail"
+		}
+	}
+	return status, results
+}
+
+func readyStatusCode(status string) int {
+	if status != "ok" {
+		return fiber.StatusServiceUnavailable
+	}
+	return fiber.StatusOK
+}
+
+// checkContentDir lists ./content and writes and removes a file in it, as
+// the admin UI saves posts and pages there. Like saving, it creates the
+// directory if it is missing.
+func (site *Site) checkContentDir(ctx context.Context) (string, error) {
+	if err := os.MkdirAll("./content", 0755); err != nil {
+		return "", err
+	}
+	if _, err := os.ReadDir("./content"); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("./content", ".readyz-*")
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	f.Close()
+	if err := os.Remove(name); err != nil {
+		return "", fmt.Errorf("could not remove %s: %v", filepath.Base(name), err)
+	}
+	return "", nil
+}
+
+// checkTemplates reports whether the page templates were parsed at
+// startup. They are not parsed again here, as that would be slow and, in
+// production, would not change what the running engine serves.
+func (site *Site) checkTemplates(ctx context.Context) (string, error) {
+	if !site.templatesLoaded {
+		return "", errors.New("templates not loaded")
+	}
+	return "", nil
+}
+
+// checkPosts reads every post. Files that cannot be read are named in the
+// detail but do not fail the check: the site serves the other posts, and
+// restarting it would not help.
+func (site *Site) checkPosts(ctx context.Context) (string, error) {
+	n, bad, err := models.CheckPostStore()
+	detail := fmt.Sprintf("%d posts", n)
+	if len(bad) > 0 {
+		detail += fmt.Sprintf(", %d skipped: %s", len(bad), strings.Join(bad, "; "))
+	}
+	return detail, err
+}
+
+func (site *Site) checkSMTP(ctx context.Context) (string, error) {
+	s := site.smtpTransport()
+	return s.Host, s.Ping(ctx)
+}
+
+func (site *Site) checkGraphAPI(ctx context.Context) (string, error) {
+	client := site.newGraphClient()
+	return client.BaseURL, client.Ping(ctx)
+}
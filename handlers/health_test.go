@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestReadyzWithBrokenPost(t *testing.T) {
+	site := newTestSite(t, nil)
+	site.TemplatesLoaded()
+	if err := os.MkdirAll("content/posts", 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile("content/posts/ok.json", []byte(`{"id": "ok", "slug": "ok", "title": "OK"}`), 0644)
+	os.WriteFile("content/posts/broken.json", []byte(`{"id": "broken",`), 0644)
+
+	app := fiber.New()
+	app.Get("/readyz", site.Readyz)
+	app.Get("/api/admin/health", site.AdminHealth)
+
+	var ready struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	if code := request(t, app, "GET", "/readyz", "", &ready); code != fiber.StatusOK || ready.Checks["posts"] != "ok" {
+		t.Errorf("/readyz answered %d with %+v, want 200 with posts ok", code, ready)
+	}
+
+	var health struct {
+		Checks map[string]readyCheckResult `json:"checks"`
+	}
+	request(t, app, "GET", "/api/admin/health", "", &health)
+	posts := health.Checks["posts"]
+	if posts.Status != "ok" || !strings.HasPrefix(posts.Detail, "1 posts, 1 skipped: broken.json: ") {
+		t.Errorf("posts check %+v, want ok naming broken.json", posts)
+	}
+}
@@ -1,26 +1,31 @@
 package handlers
 
 import (
+	"soma-mayel-campaign/i18n"
 	"soma-mayel-campaign/models"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func Home(c *fiber.Ctx) error {
+	locale := i18n.FromCtx(c)
+
 	// Get latest blog posts
-	posts := models.GetLatestPosts(3)
+	posts := models.LocalizePosts(models.GetLatestPosts(3), locale)
 
 	// Get featured content
-	featured := models.GetFeaturedContent()
+	featured := models.LocalizePosts(models.GetFeaturedContent(), locale)
+
+	siteContent := siteSettings(locale)
 
 	return c.Render("home", fiber.Map{
-		"Title":    "Soma Mayel - Spidskandidat for Radikale Venstre i Fredensborg",
+		"Title":    contentString(siteContent, i18n.T(locale, "home.title"), "siteTitle"),
 		"Posts":    posts,
 		"Featured": featured,
 		"Hero": fiber.Map{
-			"VideoURL": "/static/videos/hero-video.mp4",
-			"Title":    "Sammen skaber vi et grønnere og mere inkluderende Fredensborg",
-			"Subtitle": "Stem på Soma Mayel - Radikale Venstre",
+			"VideoURL": contentString(siteContent, "/static/videos/hero-video.mp4", "hero", "videoUrl"),
+			"Title":    contentString(siteContent, i18n.T(locale, "home.hero_title"), "hero", "title"),
+			"Subtitle": contentString(siteContent, i18n.T(locale, "home.hero_subtitle"), "hero", "subtitle"),
 		},
 	})
-}
\ No newline at end of file
+}
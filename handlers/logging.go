@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requestIDKey is where the requestid middleware keeps the request's ID
+const requestIDKey = "requestid"
+
+// RequestLogger returns middleware that logs every request with its ID,
+// status and duration, and the admin user for requests behind the login.
+// Server errors are logged at error level.
+func RequestLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := responseStatus(c, err)
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []any{
+			"method", c.Method(),
+			"path", c.Path(),
+			"status", status,
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"ip", c.IP(),
+			"bytes", len(c.Response().Body()),
+		}
+		if err != nil {
+			attrs = append(attrs, "err", err)
+		}
+		requestLog(c).Log(c.UserContext(), level, "request", attrs...)
+		return err
+	}
+}
+
+// requestLog returns the logger for a request, which adds the request ID
+// and the logged in admin user to every entry
+func requestLog(c *fiber.Ctx) *slog.Logger {
+	logger := slog.Default()
+	if id, ok := c.Locals(requestIDKey).(string); ok && id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if user, ok := c.Locals("username").(string); ok && user != "" {
+		logger = logger.With("user", user)
+	}
+	return logger
+}
+
+// serverError logs err for the request and answers 500 with message and
+// the request ID, which finds the log entry
+func serverError(c *fiber.Ctx, message string, err error) error {
+	requestLog(c).Error(message, "err", err)
+	id, _ := c.Locals(requestIDKey).(string)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message, "request_id": id})
+}
+
+// responseStatus is the status code of a response, including one that the
+// error handler has yet to set for err
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return fe.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+// LogPanic logs a panic caught by the recover middleware, with the stack,
+// under the request's ID. The middleware then answers 500.
+func LogPanic(c *fiber.Ctx, e interface{}) {
+	requestLog(c).Error("request: panic", "method", c.Method(), "path", c.Path(), "panic", fmt.Sprint(e), "stack", string(debug.Stack()))
+}
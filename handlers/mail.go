@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+
+	"soma-mayel-campaign/i18n"
+	mailer "soma-mayel-campaign/mail"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// mailTemplateDir holds the transactional e-mail templates, within the
+// site's templates
+const mailTemplateDir = "email"
+
+var mailQueue *mailer.Queue
+
+// StartMailQueue starts sending queued transactional e-mail in the
+// background
+func StartMailQueue(ctx context.Context) {
+	mailQueue = mailer.NewQueue(dataDir, nil)
+	goWorker(func() { mailQueue.Run(ctx) })
+}
+
+// queueMail renders the template called name in locale and queues it for to
+func (site *Site) queueMail(to, name, locale string, data interface{}, headers map[string]string) error {
+	msg, err := site.mailTemplates().Render(name, locale, data)
+	if err != nil {
+		slog.Error("mail: could not render template", "template", name, "locale", locale, "err", err)
+		templateRenderErrors.WithLabelValues("email/" + name).Inc()
+		return err
+	}
+	msg.To = to
+	msg.Headers = headers
+
+	if mailQueue == nil {
+		return mailer.Send(msg)
+	}
+	return mailQueue.Enqueue(msg)
+}
+
+func (site *Site) mailTemplates() mailer.Templates {
+	return mailer.Templates{
+		FS:       site.templates,
+		Dir:      mailTemplateDir,
+		Fallback: i18n.Default,
+		Funcs: map[string]interface{}{
+			"t":    i18n.T,
+			"date": i18n.FormatDate,
+		},
+	}
+}
+
+// AdminMailStatus returns the queued e-mails and those that could not be
+// delivered
+func AdminMailStatus(c *fiber.Ctx) error {
+	queued := []mailer.Job{}
+	failures := []mailer.Failure{}
+	if mailQueue != nil {
+		for _, job := range mailQueue.Jobs() {
+			// Leave the bodies out, they may contain sign-in links
+			job.Message.Text, job.Message.HTML = "", ""
+			queued = append(queued, job)
+		}
+		failures = mailQueue.Failures()
+	}
+	return c.JSON(fiber.Map{"queued": queued, "failures": failures})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"soma-mayel-campaign/facebook"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to answer HTTP requests, by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+	httpInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being answered.",
+	})
+
+	socialFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "social_fetch_total",
+		Help: "Background fetches of social media posts by source and result (ok, missing_access_token, token_expired, rate_limited or error).",
+	}, []string{"source", "result"})
+	socialCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "social_cache_requests_total",
+		Help: "Feed requests answered from the social media cache, by source and whether the items were fresh, stale or empty.",
+	}, []string{"source", "result"})
+
+	templateRenderErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "template_render_errors_total",
+		Help: "Page and e-mail templates that failed to render, by template.",
+	}, []string{"template"})
+
+	uploads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "uploads_total",
+		Help: "Images saved to static/images/uploads, by source (admin or facebook_import).",
+	}, []string{"source"})
+	uploadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "upload_bytes_total",
+		Help: "Bytes saved to static/images/uploads, by source.",
+	}, []string{"source"})
+)
+
+// Metrics returns middleware that counts requests and their latency by
+// route pattern, so /blog/:slug is one series rather than one per post.
+// Requests that match no route are counted as "unmatched".
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		err := c.Next()
+
+		route := c.Route().Path
+		status := responseStatus(c, err)
+		// Fiber's own 404 and 405 errors mean no route matched; the handlers
+		// answer with their own not found responses instead
+		if err != nil && (status == fiber.StatusNotFound || status == fiber.StatusMethodNotAllowed) {
+			route = "unmatched"
+		}
+
+		method := c.Method()
+		httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+		httpDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format
+var MetricsHandler = adaptor.HTTPHandler(promhttp.Handler())
+
+// socialFetchResult maps a fetch error to the result label of
+// social_fetch_total, keeping the number of series small
+func socialFetchResult(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case err == facebook.ErrNoAccessToken:
+		return err.Error()
+	case facebook.IsTokenExpired(err):
+		return "token_expired"
+	case facebook.IsRateLimited(err):
+		return "rate_limited"
+	}
+	return "error"
+}
+
+// CountRenderErrors wraps a template engine so that failed renders are
+// counted in template_render_errors_total
+func CountRenderErrors(views fiber.Views) fiber.Views {
+	return renderErrorCounter{views}
+}
+
+type renderErrorCounter struct {
+	fiber.Views
+}
+
+func (v renderErrorCounter) Render(w io.Writer, name string, bind interface{}, layouts ...string) error {
+	err := v.Views.Render(w, name, bind, layouts...)
+	if err != nil {
+		templateRenderErrors.WithLabelValues(name).Inc()
+	}
+	return err
+}
+
+// countUpload records an image saved by saveUpload
+func countUpload(source string, size int64) {
+	uploads.WithLabelValues(source).Inc()
+	uploadBytes.WithLabelValues(source).Add(float64(size))
+}
@@ -1,32 +1,55 @@
 package handlers
 
 import (
+	"soma-mayel-campaign/i18n"
 	"soma-mayel-campaign/models"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func News(c *fiber.Ctx) error {
-	posts := models.GetAllPosts()
-	
+	locale := i18n.FromCtx(c)
+	posts := models.LocalizePosts(models.GetPublishedPosts(), locale)
+
 	return c.Render("news", fiber.Map{
-		"Title": "Nyheder og Blog",
+		"Title": i18n.T(locale, "news.title"),
 		"Posts": posts,
 	})
 }
 
 func BlogPost(c *fiber.Ctx) error {
+	locale := i18n.FromCtx(c)
 	slug := c.Params("slug")
 	post := models.GetPostBySlug(slug)
-	
+
 	if post == nil {
-		return c.Status(404).Render("404", fiber.Map{
-			"Title": "Side ikke fundet",
-		})
+		return NotFound(c)
+	}
+
+	prev, next := models.GetAdjacentPosts(post)
+	if prev != nil {
+		p := prev.Localized(locale)
+		prev = &p
+	}
+	if next != nil {
+		n := next.Localized(locale)
+		next = &n
 	}
-	
+
+	localized := post.Localized(locale)
+
 	return c.Render("blog-post", fiber.Map{
-		"Title": post.Title,
-		"Post":  post,
+		"Title":        localized.Title,
+		"Post":         localized,
+		"RelatedPosts": models.LocalizePosts(models.GetRelatedPosts(post, locale, 3), locale),
+		"PrevPost":     prev,
+		"NextPost":     next,
 	})
-}
\ No newline at end of file
+}
+
+// NotFound renders the 404 page in the request's locale
+func NotFound(c *fiber.Ctx) error {
+	return c.Status(404).Render("404", fiber.Map{
+		"Title": i18n.T(i18n.FromCtx(c), "notfound.title"),
+	})
+}
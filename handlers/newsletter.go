@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/i18n"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	newsletterConfirmPurpose     = "newsletter-confirm"
+	newsletterUnsubscribePurpose = "newsletter-unsubscribe"
+
+	// newsletterConfirmTTL is how long a confirmation link works
+	newsletterConfirmTTL = 7 * 24 * time.Hour
+	// newsletterResendAfter stops repeated sign-ups from flooding an inbox
+	newsletterResendAfter = 10 * time.Minute
+)
+
+// newsletterStatuses are the outcomes the newsletter page can show
+var newsletterStatuses = map[string]bool{
+	"pending":      true,
+	"confirmed":    true,
+	"unsubscribed": true,
+	"invalid":      true,
+	"bad_email":    true,
+	"no_consent":   true,
+	"error":        true,
+	"retry":        true,
+	"rate_limited": true,
+}
+
+// newsletterMu serialises sign-ups so an address is never stored twice
+var newsletterMu sync.Mutex
+
+type subscribeRequest struct {
+	Email   string `json:"email" form:"email"`
+	Name    string `json:"name" form:"name"`
+	Consent bool   `json:"consent" form:"consent"`
+	Locale  string `json:"locale" form:"locale"`
+	Source  string `json:"source" form:"source"`
+}
+
+// Newsletter renders the sign-up page and the outcome of a sign-up,
+// confirmation or unsubscription
+func Newsletter(c *fiber.Ctx) error {
+	locale := i18n.FromCtx(c)
+	status := c.Query("status")
+	if !newsletterStatuses[status] {
+		status = ""
+	}
+	return c.Render("newsletter", fiber.Map{
+		"Title":  i18n.T(locale, "newsletter.title"),
+		"Status": status,
+	})
+}
+
+// NewsletterSubscribe stores a pending subscriber and e-mails them a
+// confirmation link. Plain form posts are redirected to the newsletter page;
+// JSON clients get the status back. An address that is already confirmed
+// gets the same answer as a new one, so the form cannot be used to find out
+// who is subscribed.
+func (site *Site) NewsletterSubscribe(c *fiber.Ctx) error {
+	var req subscribeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newsletterResult(c, i18n.FromCtx(c), "error", fiber.StatusBadRequest)
+	}
+	if _, ok := i18n.Get(req.Locale); !ok {
+		req.Locale = i18n.FromCtx(c)
+	}
+	if req.Source == "" {
+		req.Source = c.Get(fiber.HeaderReferer)
+	}
+
+	status, code := site.subscribeNewsletter(requestLog(c), req, site.siteURL(c.BaseURL()))
+	return newsletterResult(c, req.Locale, status, code)
+}
+
+// subscribeNewsletter signs up the address in req and returns the status
+// to show and its HTTP status code
+func (site *Site) subscribeNewsletter(logger *slog.Logger, req subscribeRequest, base string) (string, int) {
+	email := models.NormalizeEmail(req.Email)
+	if !validEmail(email) {
+		return "bad_email", fiber.StatusBadRequest
+	}
+	if !req.Consent {
+		return "no_consent", fiber.StatusBadRequest
+	}
+
+	newsletterMu.Lock()
+	defer newsletterMu.Unlock()
+
+	s := models.GetSubscriberByEmail(email)
+	if s != nil && s.Status == models.SubscriberConfirmed {
+		return "pending", fiber.StatusOK
+	}
+
+	now := time.Now()
+	if s == nil {
+		s = &models.Subscriber{Email: email, CreatedAt: now}
+	}
+	s.Status = models.SubscriberPending
+	s.Name = strings.TrimSpace(req.Name)
+	s.Locale = req.Locale
+	s.Source = sourcePath(req.Source)
+	s.ConsentText = i18n.T(req.Locale, "newsletter.consent")
+	s.UnsubscribedAt = nil
+
+	send := s.ConfirmSentAt == nil || now.Sub(*s.ConfirmSentAt) > newsletterResendAfter
+	if send {
+		s.ConfirmSentAt = &now
+	}
+	if err := models.SaveSubscriber(s); err != nil {
+		logger.Error("newsletter: could not save subscriber", "err", err)
+		return "error", fiber.StatusInternalServerError
+	}
+
+	if send {
+		if err := site.sendNewsletterConfirmation(*s, base); err != nil {
+			logger.Error("newsletter: could not queue confirmation", "subscriber_id", s.ID, "err", err)
+		}
+	}
+	return "pending", fiber.StatusOK
+}
+
+// rejectNewsletterSubmission answers a sign-up held back by ProtectForm
+func rejectNewsletterSubmission(c *fiber.Ctx, fields map[string]string, reason string) error {
+	status, code := rejectionStatus(reason, "pending")
+	return newsletterResult(c, formLocale(c, fields), status, code)
+}
+
+// releaseNewsletterSubmission signs up a quarantined submission that staff
+// let through. The address still has to be confirmed.
+func (site *Site) releaseNewsletterSubmission(logger *slog.Logger, q models.QuarantinedSubmission) error {
+	req := subscribeRequest{
+		Email:   q.Fields["email"],
+		Name:    q.Fields["name"],
+		Consent: q.Fields["consent"] == "true" || q.Fields["consent"] == "on",
+		Locale:  q.Fields["locale"],
+		Source:  q.Fields["source"],
+	}
+	if _, ok := i18n.Get(req.Locale); !ok {
+		req.Locale = i18n.Default
+	}
+	if status, code := site.subscribeNewsletter(logger, req, site.siteURL(q.BaseURL)); code != fiber.StatusOK {
+		return fmt.Errorf("sign-up failed: %s", status)
+	}
+	return nil
+}
+
+// NewsletterConfirm confirms a subscription from the link in the
+// confirmation e-mail and records when consent was given
+func (site *Site) NewsletterConfirm(c *fiber.Ctx) error {
+	newsletterMu.Lock()
+	defer newsletterMu.Unlock()
+
+	s := site.subscriberFromToken(newsletterConfirmPurpose, c.Query("token"))
+	if s == nil || s.Status == models.SubscriberUnsubscribed {
+		return newsletterResult(c, i18n.FromCtx(c), "invalid", fiber.StatusBadRequest)
+	}
+
+	if s.Status == models.SubscriberPending {
+		now := time.Now()
+		s.Status = models.SubscriberConfirmed
+		s.ConsentAt = &now
+		if err := models.SaveSubscriber(s); err != nil {
+			requestLog(c).Error("newsletter: could not save subscriber", "subscriber_id", s.ID, "err", err)
+			return newsletterResult(c, i18n.FromCtx(c), "error", fiber.StatusInternalServerError)
+		}
+	}
+	return newsletterResult(c, i18n.FromCtx(c), "confirmed", fiber.StatusOK)
+}
+
+// NewsletterUnsubscribePage asks the visitor to confirm unsubscribing, so
+// mail scanners that follow links do not unsubscribe anyone
+func (site *Site) NewsletterUnsubscribePage(c *fiber.Ctx) error {
+	locale := i18n.FromCtx(c)
+	token := c.Query("token")
+	s := site.subscriberFromToken(newsletterUnsubscribePurpose, token)
+	if s == nil {
+		return newsletterResult(c, locale, "invalid", fiber.StatusBadRequest)
+	}
+	if s.Status == models.SubscriberUnsubscribed {
+		return newsletterResult(c, locale, "unsubscribed", fiber.StatusOK)
+	}
+	return c.Render("newsletter", fiber.Map{
+		"Title":       i18n.T(locale, "newsletter.unsubscribe_title"),
+		"Unsubscribe": fiber.Map{"Email": s.Email, "Token": token},
+	})
+}
+
+// NewsletterUnsubscribe unsubscribes from the page's button or a mail
+// client's one-click List-Unsubscribe-Post request
+func (site *Site) NewsletterUnsubscribe(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		token = c.FormValue("token")
+	}
+
+	newsletterMu.Lock()
+	defer newsletterMu.Unlock()
+
+	s := site.subscriberFromToken(newsletterUnsubscribePurpose, token)
+	if s == nil {
+		return newsletterResult(c, i18n.FromCtx(c), "invalid", fiber.StatusBadRequest)
+	}
+	if s.Status != models.SubscriberUnsubscribed {
+		now := time.Now()
+		s.Status = models.SubscriberUnsubscribed
+		s.UnsubscribedAt = &now
+		if err := models.SaveSubscriber(s); err != nil {
+			requestLog(c).Error("newsletter: could not save subscriber", "subscriber_id", s.ID, "err", err)
+			return newsletterResult(c, i18n.FromCtx(c), "error", fiber.StatusInternalServerError)
+		}
+	}
+	return newsletterResult(c, i18n.FromCtx(c), "unsubscribed", fiber.StatusOK)
+}
+
+// AdminListSubscribers returns all subscribers with counts per status
+func AdminListSubscribers(c *fiber.Ctx) error {
+	subscribers := models.GetAllSubscribers()
+	counts := map[string]int{
+		models.SubscriberPending:      0,
+		models.SubscriberConfirmed:    0,
+		models.SubscriberUnsubscribed: 0,
+	}
+	for _, s := range subscribers {
+		counts[s.Status]++
+	}
+	if subscribers == nil {
+		subscribers = []models.Subscriber{}
+	}
+	return c.JSON(fiber.Map{"subscribers": subscribers, "counts": counts})
+}
+
+// newsletterResult answers JSON clients with the status and redirects form
+// posts and links to the newsletter page that explains it
+func newsletterResult(c *fiber.Ctx, locale, status string, code int) error {
+	if c.Is("json") || c.Accepts(fiber.MIMETextHTML, fiber.MIMEApplicationJSON) == fiber.MIMEApplicationJSON {
+		if code >= fiber.StatusBadRequest {
+			return c.Status(code).JSON(fiber.Map{"error": status})
+		}
+		return c.JSON(fiber.Map{"status": status})
+	}
+	return c.Redirect(i18n.Prefix(locale)+"/nyhedsbrev?status="+status, fiber.StatusSeeOther)
+}
+
+// sendNewsletterConfirmation queues the double opt-in e-mail
+func (site *Site) sendNewsletterConfirmation(s models.Subscriber, base string) error {
+	link := base + i18n.Prefix(s.Locale) + "/nyhedsbrev/bekraeft?token=" + url.QueryEscape(site.signValue(newsletterConfirmPurpose, s.ID, time.Now().Add(newsletterConfirmTTL)))
+	data := fiber.Map{"Name": s.Name, "ConfirmURL": link}
+	return site.queueMail(s.Email, "newsletter_confirm", s.Locale, data, site.newsletterUnsubscribeHeaders(s, base))
+}
+
+// newsletterUnsubscribeURL returns the subscriber's permanent unsubscribe
+// link, for the footer and List-Unsubscribe header of every newsletter
+func (site *Site) newsletterUnsubscribeURL(s models.Subscriber, base string) string {
+	return base + i18n.Prefix(s.Locale) + "/nyhedsbrev/afmeld?token=" + url.QueryEscape(site.signValue(newsletterUnsubscribePurpose, s.ID, time.Time{}))
+}
+
+// newsletterUnsubscribeHeaders let mail clients offer one-click unsubscribe
+func (site *Site) newsletterUnsubscribeHeaders(s models.Subscriber, base string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + site.newsletterUnsubscribeURL(s, base) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
+
+// subscriberFromToken returns the subscriber a signed link was made for
+func (site *Site) subscriberFromToken(purpose, token string) *models.Subscriber {
+	id, err := site.verifySigned(purpose, token)
+	if err != nil {
+		return nil
+	}
+	return models.GetSubscriberByID(id)
+}
+
+// validEmail accepts a bare address such as name@example.dk
+func validEmail(email string) bool {
+	if len(email) > 254 {
+		return false
+	}
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email && strings.Contains(email[strings.LastIndex(email, "@")+1:], ".")
+}
+
+// sourcePath reduces a page URL to its path so only the page is recorded
+func sourcePath(source string) string {
+	u, err := url.Parse(source)
+	if err != nil || u.Path == "" {
+		return ""
+	}
+	return u.Path
+}
@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	htmltemplate "html/template"
+	"log/slog"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"soma-mayel-campaign/i18n"
+	mailer "soma-mayel-campaign/mail"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	newsletterHTMLTemplate = "email/newsletter.html"
+	newsletterTextTemplate = "email/newsletter.txt"
+
+	// newsletterMaxAttempts is how often delivery to a recipient is tried
+	// before it is marked as failed
+	newsletterMaxAttempts = 3
+)
+
+// campaignMu guards reading and writing campaigns, which both the admin API
+// and the sender change
+var campaignMu sync.Mutex
+
+var newsletterSender struct {
+	mu   sync.Mutex
+	kick chan struct{}
+}
+
+type upsertCampaignRequest struct {
+	ID      string   `json:"id"`
+	Subject string   `json:"subject"`
+	Intro   string   `json:"intro"`
+	PostIDs []string `json:"post_ids"`
+}
+
+// campaignSummary is a campaign without its recipient list
+type campaignSummary struct {
+	models.Campaign
+	Recipients []models.CampaignRecipient `json:"recipients,omitempty"`
+	Counts     map[string]int             `json:"counts"`
+}
+
+// newsletterEmail is the data the newsletter templates are rendered with
+type newsletterEmail struct {
+	Locale         string
+	Dir            string
+	Subject        string
+	Name           string
+	Intro          []string
+	Posts          []newsletterEmailPost
+	SiteURL        string
+	UnsubscribeURL string
+}
+
+type newsletterEmailPost struct {
+	Title   string
+	Excerpt string
+	Date    time.Time
+	URL     string
+	Image   string
+}
+
+// newsletterTemplates are the parsed HTML and plain-text newsletter templates
+type newsletterTemplates struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// AdminListCampaigns returns all campaigns with recipient counts
+func AdminListCampaigns(c *fiber.Ctx) error {
+	campaignMu.Lock()
+	campaigns := models.GetAllCampaigns()
+	campaignMu.Unlock()
+
+	summaries := make([]campaignSummary, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		summaries = append(summaries, campaignSummary{Campaign: campaign, Counts: campaign.RecipientCounts()})
+	}
+	return c.JSON(summaries)
+}
+
+// AdminGetCampaign returns a campaign with the delivery state of every
+// recipient
+func AdminGetCampaign(c *fiber.Ctx) error {
+	campaignMu.Lock()
+	campaign := models.GetCampaignByID(c.Params("id"))
+	campaignMu.Unlock()
+	if campaign == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "campaign not found"})
+	}
+	return c.JSON(campaignSummary{Campaign: *campaign, Recipients: campaign.Recipients, Counts: campaign.RecipientCounts()})
+}
+
+// AdminUpsertCampaign creates or updates a draft campaign
+func AdminUpsertCampaign(c *fiber.Ctx) error {
+	var req upsertCampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
+	}
+	req.Subject = strings.TrimSpace(req.Subject)
+	if req.Subject == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "subject is required"})
+	}
+	postIDs := []string{}
+	for _, id := range req.PostIDs {
+		post := models.GetPostByID(id)
+		if post == nil || post.Draft {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown or unpublished post " + id})
+		}
+		postIDs = append(postIDs, id)
+	}
+
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+
+	now := time.Now()
+	campaign := &models.Campaign{Status: models.CampaignDraft, CreatedAt: now}
+	if req.ID != "" {
+		campaign = models.GetCampaignByID(req.ID)
+		if campaign == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "campaign not found"})
+		}
+		if campaign.Status != models.CampaignDraft {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "campaign has already been sent"})
+		}
+	}
+	campaign.Subject = req.Subject
+	campaign.Intro = req.Intro
+	campaign.PostIDs = postIDs
+	campaign.UpdatedAt = now
+
+	if err := models.SaveCampaign(campaign); err != nil {
+		return serverError(c, "failed to save campaign", err)
+	}
+	return c.JSON(campaignSummary{Campaign: *campaign, Counts: campaign.RecipientCounts()})
+}
+
+// AdminDeleteCampaign deletes a campaign that is not being sent
+func AdminDeleteCampaign(c *fiber.Ctx) error {
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+
+	campaign := models.GetCampaignByID(c.Params("id"))
+	if campaign == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "campaign not found"})
+	}
+	if campaign.Status == models.CampaignSending {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "campaign is being sent"})
+	}
+	if err := models.DeleteCampaign(campaign.ID); err != nil {
+		return serverError(c, "failed to delete campaign", err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AdminPreviewCampaign renders a campaign as a subscriber in the given
+// locale would see it. ?format=text shows the plain-text version.
+func (site *Site) AdminPreviewCampaign(c *fiber.Ctx) error {
+	campaignMu.Lock()
+	campaign := models.GetCampaignByID(c.Params("id"))
+	campaignMu.Unlock()
+	if campaign == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "campaign not found"})
+	}
+
+	tmpl, err := site.loadNewsletterTemplates()
+	if err != nil {
+		return serverError(c, "failed to load templates", err)
+	}
+	msg, err := site.renderNewsletter(tmpl, campaign, previewSubscriber(c.Query("locale"), ""), site.siteURL(c.BaseURL()))
+	if err != nil {
+		return serverError(c, "failed to render campaign", err)
+	}
+
+	if c.Query("format") == "text" {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString(msg.Subject + "\n\n" + msg.Text)
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.SendString(msg.HTML)
+}
+
+// AdminTestCampaign sends a campaign to the editor, by default to
+// CONTACT_EMAIL, with a [TEST] subject
+func (site *Site) AdminTestCampaign(c *fiber.Ctx) error {
+	var req struct {
+		Email  string `json:"email"`
+		Locale string `json:"locale"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid JSON"})
+	}
+	email := models.NormalizeEmail(req.Email)
+	if email == "" {
+		email = models.NormalizeEmail(site.cfg.Mail.ContactEmail)
+	}
+	if !validEmail(email) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "a valid e-mail address is required"})
+	}
+
+	campaignMu.Lock()
+	campaign := models.GetCampaignByID(c.Params("id"))
+	campaignMu.Unlock()
+	if campaign == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "campaign not found"})
+	}
+
+	tmpl, err := site.loadNewsletterTemplates()
+	if err != nil {
+		return serverError(c, "failed to load templates", err)
+	}
+	msg, err := site.renderNewsletter(tmpl, campaign, previewSubscriber(req.Locale, email), site.siteURL(c.BaseURL()))
+	if err != nil {
+		return serverError(c, "failed to render campaign", err)
+	}
+	msg.Subject = "[TEST] " + msg.Subject
+	if err := mailer.Send(msg); err != nil {
+		requestLog(c).Error("newsletter: test send failed", "campaign_id", campaign.ID, "err", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "failed to send test: " + err.Error()})
+	}
+
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+	if latest := models.GetCampaignByID(campaign.ID); latest != nil {
+		now := time.Now()
+		latest.TestSentTo = email
+		latest.TestSentAt = &now
+		if err := models.SaveCampaign(latest); err != nil {
+			requestLog(c).Error("newsletter: could not save campaign", "campaign_id", latest.ID, "err", err)
+		}
+		campaign = latest
+	}
+	return c.JSON(campaignSummary{Campaign: *campaign, Counts: campaign.RecipientCounts()})
+}
+
+// AdminSendCampaign starts delivering a draft campaign to every confirmed
+// subscriber. The sender delivers it in batches in the background.
+func AdminSendCampaign(c *fiber.Ctx) error {
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+
+	campaign := models.GetCampaignByID(c.Params("id"))
+	if campaign == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "campaign not found"})
+	}
+	if campaign.Status != models.CampaignDraft {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "campaign has already been sent"})
+	}
+
+	subscribers := models.GetConfirmedSubscribers()
+	if len(subscribers) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "there are no confirmed subscribers"})
+	}
+
+	now := time.Now()
+	campaign.Recipients = make([]models.CampaignRecipient, 0, len(subscribers))
+	for _, s := range subscribers {
+		campaign.Recipients = append(campaign.Recipients, models.CampaignRecipient{
+			SubscriberID: s.ID,
+			Email:        s.Email,
+			Status:       models.RecipientPending,
+		})
+	}
+	campaign.Status = models.CampaignSending
+	campaign.BaseURL = c.BaseURL()
+	campaign.StartedAt = &now
+	campaign.UpdatedAt = now
+	if err := models.SaveCampaign(campaign); err != nil {
+		return serverError(c, "failed to save campaign", err)
+	}
+	requestLog(c).Info("newsletter: sending campaign", "campaign_id", campaign.ID, "recipients", len(campaign.Recipients))
+
+	kickNewsletterSender()
+	return c.JSON(campaignSummary{Campaign: *campaign, Counts: campaign.RecipientCounts()})
+}
+
+// StartNewsletterSender delivers campaigns in the background, at most
+// NEWSLETTER_BATCH_SIZE e-mails every NEWSLETTER_BATCH_INTERVAL. Campaigns
+// that were sending when the server stopped carry on where they left off.
+// It stops between two e-mails when ctx is cancelled.
+func (site *Site) StartNewsletterSender(ctx context.Context) {
+	newsletterSender.mu.Lock()
+	newsletterSender.kick = make(chan struct{}, 1)
+	kick := newsletterSender.kick
+	newsletterSender.mu.Unlock()
+
+	batchSize := site.cfg.Newsletter.BatchSize
+	interval := site.cfg.Newsletter.BatchInterval
+
+	goWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// After a batch only the ticker may start the next one, a full
+			// interval later, so starting another campaign cannot exceed
+			// the rate
+			if site.sendNewsletterBatch(ctx, batchSize) > 0 {
+				ticker.Reset(interval)
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			select {
+			case <-ticker.C:
+			case <-kick:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+func kickNewsletterSender() {
+	newsletterSender.mu.Lock()
+	kick := newsletterSender.kick
+	newsletterSender.mu.Unlock()
+
+	if kick != nil {
+		select {
+		case kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// sendNewsletterBatch tries up to limit pending recipients across the
+// campaigns being sent, oldest campaign first, and returns how many it
+// tried. It stops early when ctx is cancelled; the recipients left over are
+// still pending after a restart.
+func (site *Site) sendNewsletterBatch(ctx context.Context, limit int) int {
+	campaignMu.Lock()
+	var sending []models.Campaign
+	for _, campaign := range models.GetAllCampaigns() {
+		if campaign.Status == models.CampaignSending {
+			sending = append([]models.Campaign{campaign}, sending...)
+		}
+	}
+	campaignMu.Unlock()
+	if len(sending) == 0 {
+		return 0
+	}
+
+	tmpl, err := site.loadNewsletterTemplates()
+	if err != nil {
+		slog.Error("newsletter: not sending, templates failed", "err", err)
+		return 0
+	}
+
+	tried := 0
+	for _, campaign := range sending {
+		base := site.siteURL(campaign.BaseURL)
+		outcomes := map[string]newsletterOutcome{}
+		for _, r := range campaign.Recipients {
+			if tried >= limit || ctx.Err() != nil {
+				break
+			}
+			if r.Status != models.RecipientPending {
+				continue
+			}
+			tried++
+			var o newsletterOutcome
+			o.status, o.reason, o.retry = site.deliverNewsletter(tmpl, &campaign, r, base)
+			outcomes[r.SubscriberID] = o
+		}
+		recordNewsletterDeliveries(campaign.ID, outcomes)
+		if tried >= limit || ctx.Err() != nil {
+			break
+		}
+	}
+	return tried
+}
+
+// newsletterOutcome is the result of one delivery attempt, until it is
+// recorded in the campaign
+type newsletterOutcome struct {
+	status string
+	reason string
+	retry  bool
+}
+
+// deliverNewsletter sends a campaign to one recipient and returns the
+// recipient's new status and whether a failure is worth retrying
+func (site *Site) deliverNewsletter(tmpl *newsletterTemplates, campaign *models.Campaign, r models.CampaignRecipient, base string) (string, string, bool) {
+	s := models.GetSubscriberByID(r.SubscriberID)
+	if s == nil || s.Status != models.SubscriberConfirmed {
+		return models.RecipientSkipped, "no longer subscribed", false
+	}
+	msg, err := site.renderNewsletter(tmpl, campaign, *s, base)
+	if err == nil {
+		err = mailer.Send(msg)
+	}
+	if err != nil {
+		slog.Warn("newsletter: delivery failed", "campaign_id", campaign.ID, "subscriber_id", r.SubscriberID, "err", err)
+		return models.RecipientFailed, err.Error(), !mailer.IsPermanent(err)
+	}
+	return models.RecipientSent, "", false
+}
+
+// recordNewsletterDeliveries saves the outcomes of a batch, keyed by
+// subscriber ID, in one write, so a restart resumes with the recipients
+// that are still pending. Failed deliveries worth retrying stay pending
+// until they have been tried newsletterMaxAttempts times. The campaign is
+// marked as sent once no recipient is pending.
+func recordNewsletterDeliveries(campaignID string, outcomes map[string]newsletterOutcome) {
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+
+	campaign := models.GetCampaignByID(campaignID)
+	if campaign == nil || campaign.Status != models.CampaignSending {
+		return
+	}
+	now := time.Now()
+	for i := range campaign.Recipients {
+		r := &campaign.Recipients[i]
+		o, ok := outcomes[r.SubscriberID]
+		if !ok {
+			continue
+		}
+		r.Attempts++
+		r.Error = o.reason
+		switch {
+		case o.status == models.RecipientSent:
+			sentAt := now
+			r.Status = o.status
+			r.SentAt = &sentAt
+		case o.retry && r.Attempts < newsletterMaxAttempts:
+			r.Status = models.RecipientPending
+		default:
+			r.Status = o.status
+		}
+	}
+
+	counts := campaign.RecipientCounts()
+	finished := counts[models.RecipientPending] == 0
+	if len(outcomes) == 0 && !finished {
+		return
+	}
+	campaign.UpdatedAt = now
+	if finished {
+		campaign.Status = models.CampaignSent
+		campaign.FinishedAt = &now
+	}
+	if err := models.SaveCampaign(campaign); err != nil {
+		slog.Error("newsletter: could not save campaign", "campaign_id", campaign.ID, "err", err)
+		return
+	}
+	if finished {
+		slog.Info("newsletter: campaign finished", "campaign_id", campaign.ID,
+			"sent", counts[models.RecipientSent], "failed", counts[models.RecipientFailed], "skipped", counts[models.RecipientSkipped])
+	}
+}
+
+// loadNewsletterTemplates parses the newsletter templates. They are read
+// for every batch so edits show up without a restart.
+func (site *Site) loadNewsletterTemplates() (*newsletterTemplates, error) {
+	html, err := htmltemplate.New("newsletter.html").Funcs(htmltemplate.FuncMap{
+		"t":    i18n.T,
+		"date": i18n.FormatDate,
+	}).ParseFS(site.templates, newsletterHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New("newsletter.txt").Funcs(texttemplate.FuncMap{
+		"t":    i18n.T,
+		"date": i18n.FormatDate,
+	}).ParseFS(site.templates, newsletterTextTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &newsletterTemplates{html: html, text: text}, nil
+}
+
+// renderNewsletter builds the e-mail for one subscriber, with the posts
+// and the e-mail's own wording in the subscriber's language
+func (site *Site) renderNewsletter(tmpl *newsletterTemplates, campaign *models.Campaign, s models.Subscriber, base string) (mailer.Message, error) {
+	locale, ok := i18n.Get(s.Locale)
+	if !ok {
+		locale, _ = i18n.Get(i18n.Default)
+	}
+	data := newsletterEmail{
+		Locale:         locale.Code,
+		Dir:            locale.Dir,
+		Subject:        campaign.Subject,
+		Name:           s.Name,
+		Intro:          paragraphs(campaign.Intro),
+		SiteURL:        base + i18n.Prefix(locale.Code) + "/",
+		UnsubscribeURL: site.newsletterUnsubscribeURL(s, base),
+	}
+	for _, id := range campaign.PostIDs {
+		post := models.GetPostByID(id)
+		if post == nil || post.Draft {
+			continue
+		}
+		p := post.Localized(locale.Code)
+		data.Posts = append(data.Posts, newsletterEmailPost{
+			Title:   p.Title,
+			Excerpt: p.Excerpt,
+			Date:    p.Date,
+			URL:     base + i18n.Prefix(locale.Code) + "/blog/" + p.Slug,
+			Image:   absoluteURL(base, p.Image),
+		})
+	}
+
+	var html, text bytes.Buffer
+	if err := tmpl.html.Execute(&html, data); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := tmpl.text.Execute(&text, data); err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{
+		To:      s.Email,
+		Subject: campaign.Subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: site.newsletterUnsubscribeHeaders(s, base),
+	}, nil
+}
+
+// previewSubscriber stands in for a subscriber in previews and tests. Its
+// unsubscribe link does not unsubscribe anyone.
+func previewSubscriber(locale, email string) models.Subscriber {
+	if _, ok := i18n.Get(locale); !ok {
+		locale = i18n.Default
+	}
+	return models.Subscriber{ID: "preview", Email: email, Locale: locale, Status: models.SubscriberConfirmed}
+}
+
+// paragraphs splits free text on blank lines
+func paragraphs(text string) []string {
+	var out []string
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
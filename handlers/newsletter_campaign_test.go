@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mailer "soma-mayel-campaign/mail"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// recordingTransport records the e-mails sent through mailer.Send and
+// fails those to addresses listed in fail
+type recordingTransport struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+	fail map[string]error
+}
+
+func (r *recordingTransport) Send(msg mailer.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.fail[msg.To]; err != nil {
+		return err
+	}
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func (r *recordingTransport) messages() []mailer.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]mailer.Message(nil), r.sent...)
+}
+
+// newCampaignSite returns a site using the repository's e-mail templates
+// and an app serving the campaign endpoints. E-mail goes to the returned
+// transport, and a published and a draft post are saved.
+func newCampaignSite(t *testing.T) (*Site, *fiber.App, *recordingTransport) {
+	t.Helper()
+	templates, err := filepath.Abs("../templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := newTestSite(t, nil)
+	site.cfg.Server.SiteURL = "https://example.org"
+	site.templates = os.DirFS(templates)
+
+	transport := &recordingTransport{fail: map[string]error{}}
+	previous := mailer.DefaultTransport
+	mailer.DefaultTransport = transport
+	t.Cleanup(func() { mailer.DefaultTransport = previous })
+
+	for _, p := range []*models.Post{
+		{ID: "gron-omstilling", Slug: "gron-omstilling", Title: "Grøn omstilling", Excerpt: "Flere cykelstier", Date: time.Now()},
+		{ID: "kladde", Slug: "kladde", Title: "Kladde", Draft: true},
+	} {
+		if err := models.SavePost(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	app.Get("/campaigns/:id", AdminGetCampaign)
+	app.Post("/campaigns", AdminUpsertCampaign)
+	app.Delete("/campaigns/:id", AdminDeleteCampaign)
+	app.Get("/campaigns/:id/preview", site.AdminPreviewCampaign)
+	app.Post("/campaigns/:id/test", site.AdminTestCampaign)
+	app.Post("/campaigns/:id/send", AdminSendCampaign)
+	return site, app, transport
+}
+
+// addSubscriber saves a confirmed subscriber and returns its ID
+func addSubscriber(t *testing.T, email, locale string) string {
+	t.Helper()
+	now := time.Now()
+	s := &models.Subscriber{Email: email, Locale: locale, Status: models.SubscriberConfirmed, ConsentAt: &now, CreatedAt: now}
+	if err := models.SaveSubscriber(s); err != nil {
+		t.Fatal(err)
+	}
+	return s.ID
+}
+
+// createCampaign creates a draft campaign with the published post and
+// returns its ID
+func createCampaign(t *testing.T, app *fiber.App) string {
+	t.Helper()
+	var campaign campaignSummary
+	body := `{"subject": "Nyt fra Soma", "intro": "Kære vælger\n\nHer er nyt.", "post_ids": ["gron-omstilling"]}`
+	if code := request(t, app, "POST", "/campaigns", body, &campaign); code != fiber.StatusOK {
+		t.Fatalf("create answered %d", code)
+	}
+	return campaign.ID
+}
+
+func TestAdminUpsertCampaign(t *testing.T) {
+	_, app, _ := newCampaignSite(t)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "no subject", body: `{"subject": "  "}`, want: fiber.StatusBadRequest},
+		{name: "unknown post", body: `{"subject": "Nyt", "post_ids": ["findes-ikke"]}`, want: fiber.StatusBadRequest},
+		{name: "draft post", body: `{"subject": "Nyt", "post_ids": ["kladde"]}`, want: fiber.StatusBadRequest},
+		{name: "unknown campaign", body: `{"id": "findes-ikke", "subject": "Nyt"}`, want: fiber.StatusNotFound},
+		{name: "invalid JSON", body: `{`, want: fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := request(t, app, "POST", "/campaigns", tt.body, nil); code != tt.want {
+				t.Errorf("answered %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	id := createCampaign(t, app)
+	var campaign campaignSummary
+	if code := request(t, app, "POST", "/campaigns", `{"id": "`+id+`", "subject": " Nyt fra Soma Mayel "}`, &campaign); code != fiber.StatusOK {
+		t.Fatalf("update answered %d", code)
+	}
+	if campaign.ID != id || campaign.Subject != "Nyt fra Soma Mayel" || campaign.Status != models.CampaignDraft || len(campaign.PostIDs) != 0 {
+		t.Errorf("updated campaign %+v", campaign.Campaign)
+	}
+
+	// Once sending has started the campaign cannot change
+	addSubscriber(t, "soma.fan@example.org", "da")
+	request(t, app, "POST", "/campaigns/"+id+"/send", "", nil)
+	if code := request(t, app, "POST", "/campaigns", `{"id": "`+id+`", "subject": "Rettet"}`, nil); code != fiber.StatusConflict {
+		t.Errorf("update while sending answered %d, want 409", code)
+	}
+}
+
+func TestAdminSendCampaign(t *testing.T) {
+	site, app, transport := newCampaignSite(t)
+	id := createCampaign(t, app)
+
+	if code := request(t, app, "POST", "/campaigns/"+id+"/send", "", nil); code != fiber.StatusBadRequest {
+		t.Errorf("send without subscribers answered %d, want 400", code)
+	}
+	if code := request(t, app, "POST", "/campaigns/findes-ikke/send", "", nil); code != fiber.StatusNotFound {
+		t.Errorf("send of an unknown campaign answered %d, want 404", code)
+	}
+
+	addSubscriber(t, "soma.fan@example.org", "da")
+	addSubscriber(t, "soma.supporter@example.org", "en")
+	models.SaveSubscriber(&models.Subscriber{Email: "pending@example.org", Status: models.SubscriberPending, CreatedAt: time.Now()})
+
+	var campaign campaignSummary
+	if code := request(t, app, "POST", "/campaigns/"+id+"/send", "", &campaign); code != fiber.StatusOK {
+		t.Fatalf("send answered %d", code)
+	}
+	if campaign.Status != models.CampaignSending || campaign.Counts[models.RecipientPending] != 2 {
+		t.Errorf("campaign %s with counts %v, want sending to the 2 confirmed subscribers", campaign.Status, campaign.Counts)
+	}
+	if code := request(t, app, "POST", "/campaigns/"+id+"/send", "", nil); code != fiber.StatusConflict {
+		t.Errorf("second send answered %d, want 409", code)
+	}
+	if code := request(t, app, "DELETE", "/campaigns/"+id, "", nil); code != fiber.StatusConflict {
+		t.Errorf("delete while sending answered %d, want 409", code)
+	}
+
+	// A batch stops at its limit and the rest stays pending
+	if tried := site.sendNewsletterBatch(context.Background(), 1); tried != 1 {
+		t.Errorf("first batch tried %d, want 1", tried)
+	}
+	request(t, app, "GET", "/campaigns/"+id, "", &campaign)
+	if campaign.Status != models.CampaignSending || campaign.Counts[models.RecipientSent] != 1 || campaign.Counts[models.RecipientPending] != 1 {
+		t.Errorf("after one batch: %s with counts %v", campaign.Status, campaign.Counts)
+	}
+
+	if tried := site.sendNewsletterBatch(context.Background(), 10); tried != 1 {
+		t.Errorf("second batch tried %d, want 1", tried)
+	}
+	request(t, app, "GET", "/campaigns/"+id, "", &campaign)
+	if campaign.Status != models.CampaignSent || campaign.FinishedAt == nil || campaign.Counts[models.RecipientSent] != 2 {
+		t.Errorf("after two batches: %s with counts %v, want sent to both", campaign.Status, campaign.Counts)
+	}
+	if tried := site.sendNewsletterBatch(context.Background(), 10); tried != 0 {
+		t.Errorf("batch after the campaign finished tried %d", tried)
+	}
+
+	sent := transport.messages()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d e-mails, want 2", len(sent))
+	}
+	for _, msg := range sent {
+		if msg.Subject != "Nyt fra Soma" || msg.Headers["List-Unsubscribe"] == "" {
+			t.Errorf("e-mail to %s: subject %q, headers %v", msg.To, msg.Subject, msg.Headers)
+		}
+		// Each subscriber gets links in their own language
+		want := "https://example.org/blog/gron-omstilling"
+		if msg.To == "soma.supporter@example.org" {
+			want = "https://example.org/en/blog/gron-omstilling"
+		}
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("e-mail to %s does not link to %s:\n%s", msg.To, want, msg.Text)
+		}
+	}
+
+	if code := request(t, app, "DELETE", "/campaigns/"+id, "", nil); code != fiber.StatusNoContent {
+		t.Errorf("delete after sending answered %d, want 204", code)
+	}
+}
+
+func TestNewsletterDeliveryFailures(t *testing.T) {
+	site, app, transport := newCampaignSite(t)
+	id := createCampaign(t, app)
+
+	delivered := addSubscriber(t, "soma.fan@example.org", "da")
+	unreachable := addSubscriber(t, "unreachable@example.org", "da")
+	unknown := addSubscriber(t, "unknown@example.org", "da")
+	leaving := addSubscriber(t, "leaving@example.org", "da")
+	transport.fail["unreachable@example.org"] = &textproto.Error{Code: 451, Msg: "Try again later"}
+	transport.fail["unknown@example.org"] = &textproto.Error{Code: 550, Msg: "No such user"}
+
+	if code := request(t, app, "POST", "/campaigns/"+id+"/send", "", nil); code != fiber.StatusOK {
+		t.Fatalf("send answered %d", code)
+	}
+	// Unsubscribing after sending started skips the subscriber
+	s := models.GetSubscriberByID(leaving)
+	s.Status = models.SubscriberUnsubscribed
+	models.SaveSubscriber(s)
+
+	for i := 0; i < newsletterMaxAttempts+1; i++ {
+		site.sendNewsletterBatch(context.Background(), 10)
+	}
+
+	campaign := models.GetCampaignByID(id)
+	if campaign.Status != models.CampaignSent {
+		t.Errorf("campaign is %s, want sent", campaign.Status)
+	}
+	want := map[string]struct {
+		status   string
+		attempts int
+	}{
+		delivered:   {models.RecipientSent, 1},
+		unreachable: {models.RecipientFailed, newsletterMaxAttempts},
+		unknown:     {models.RecipientFailed, 1},
+		leaving:     {models.RecipientSkipped, 1},
+	}
+	for _, r := range campaign.Recipients {
+		w := want[r.SubscriberID]
+		if r.Status != w.status || r.Attempts != w.attempts {
+			t.Errorf("%s: %s after %d attempts, want %s after %d", r.Email, r.Status, r.Attempts, w.status, w.attempts)
+		}
+		if r.Status == models.RecipientFailed && r.Error == "" {
+			t.Errorf("%s failed without a reason", r.Email)
+		}
+	}
+	if sent := transport.messages(); len(sent) != 1 || sent[0].To != "soma.fan@example.org" {
+		t.Errorf("delivered %+v, want only the e-mail to soma.fan@example.org", sent)
+	}
+}
+
+func TestAdminPreviewCampaign(t *testing.T) {
+	_, app, transport := newCampaignSite(t)
+	id := createCampaign(t, app)
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	code, html := get("/campaigns/" + id + "/preview?locale=en")
+	if code != fiber.StatusOK || !strings.Contains(html, "Grøn omstilling") || !strings.Contains(html, "Her er nyt.") ||
+		!strings.Contains(html, "https://example.org/en/blog/gron-omstilling") {
+		t.Errorf("HTML preview answered %d:\n%s", code, html)
+	}
+	code, text := get("/campaigns/" + id + "/preview?format=text")
+	if code != fiber.StatusOK || !strings.HasPrefix(text, "Nyt fra Soma\n\n") || strings.Contains(text, "<p>") {
+		t.Errorf("text preview answered %d:\n%s", code, text)
+	}
+	if code, _ := get("/campaigns/findes-ikke/preview"); code != fiber.StatusNotFound {
+		t.Errorf("preview of an unknown campaign answered %d, want 404", code)
+	}
+
+	var campaign campaignSummary
+	if code := request(t, app, "POST", "/campaigns/"+id+"/test", `{"email": "Redaktion@example.org"}`, &campaign); code != fiber.StatusOK {
+		t.Fatalf("test send answered %d", code)
+	}
+	if campaign.TestSentTo != "redaktion@example.org" || campaign.TestSentAt == nil || campaign.Status != models.CampaignDraft {
+		t.Errorf("campaign after a test send %+v", campaign.Campaign)
+	}
+	if sent := transport.messages(); len(sent) != 1 || sent[0].Subject != "[TEST] Nyt fra Soma" || sent[0].To != "redaktion@example.org" {
+		t.Errorf("test send delivered %+v", sent)
+	}
+
+	transport.fail["redaktion@example.org"] = &textproto.Error{Code: 550, Msg: "No such user"}
+	if code := request(t, app, "POST", "/campaigns/"+id+"/test", `{"email": "redaktion@example.org"}`, nil); code != fiber.StatusBadGateway {
+		t.Errorf("failed test send answered %d, want 502", code)
+	}
+	if code := request(t, app, "POST", "/campaigns/"+id+"/test", `{"email": "not an address"}`, nil); code != fiber.StatusBadRequest {
+		t.Errorf("test send to an invalid address answered %d, want 400", code)
+	}
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	mailer "soma-mayel-campaign/mail"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Erasure modes. Pseudonymising keeps a record's dates and statistics but
+// replaces the address with a pseudonym and drops names and free text.
+const (
+	eraseDelete       = "delete"
+	erasePseudonymise = "pseudonymise"
+)
+
+// personalDataSource is a store that holds records about people, keyed by
+// e-mail address. Every such store must be listed in personalDataSources so
+// access and erasure requests cover it.
+type personalDataSource struct {
+	name  string
+	find  func(email string) []interface{}
+	erase func(email string, pseudonymise bool) (int, error)
+}
+
+func (site *Site) personalDataSources() []personalDataSource {
+	return []personalDataSource{
+		{name: "newsletter_subscribers", find: findSubscribers, erase: site.eraseSubscribers},
+		{name: "newsletter_deliveries", find: findNewsletterDeliveries, erase: site.eraseNewsletterDeliveries},
+		{name: "form_quarantine", find: findQuarantined, erase: eraseQuarantined},
+		{name: "outgoing_mail", find: findOutgoingMail, erase: eraseOutgoingMail},
+	}
+}
+
+type privacyRequest struct {
+	Email  string `json:"email"`
+	Mode   string `json:"mode"`
+	Format string `json:"format"`
+	Reason string `json:"reason"`
+}
+
+// personalDataExport is the bundle handed to a person who asked for a copy
+// of their data
+type personalDataExport struct {
+	Email      string                   `json:"email"`
+	ExportedAt time.Time                `json:"exported_at"`
+	Records    map[string][]interface{} `json:"records"`
+}
+
+// AdminPrivacyLookup counts the records held about an e-mail address and
+// lists earlier requests for it
+func (site *Site) AdminPrivacyLookup(c *fiber.Ctx) error {
+	req, err := parsePrivacyRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	counts := map[string]int{}
+	for _, source := range site.personalDataSources() {
+		counts[source.name] = len(source.find(req.Email))
+	}
+	entries, err := models.GetAuditLog()
+	if err != nil {
+		return serverError(c, "could not read audit log", err)
+	}
+	subject := site.emailPseudonym(req.Email)
+	history := []models.AuditEntry{}
+	for _, e := range entries {
+		if e.Subject == subject {
+			history = append(history, e)
+		}
+	}
+	return c.JSON(fiber.Map{"email": req.Email, "subject": subject, "counts": counts, "history": history})
+}
+
+// AdminPrivacyExport returns everything held about an e-mail address, as
+// JSON or as a ZIP with one file per store, for an access request
+func (site *Site) AdminPrivacyExport(c *fiber.Ctx) error {
+	req, err := parsePrivacyRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	export := personalDataExport{Email: req.Email, ExportedAt: time.Now(), Records: map[string][]interface{}{}}
+	counts := map[string]int{}
+	for _, source := range site.personalDataSources() {
+		records := source.find(req.Email)
+		if records == nil {
+			records = []interface{}{}
+		}
+		export.Records[source.name] = records
+		counts[source.name] = len(records)
+	}
+
+	var body []byte
+	filename := "persondata-" + export.ExportedAt.Format("2006-01-02")
+	if req.Format == "zip" {
+		body, err = zipExport(export)
+		filename += ".zip"
+		c.Type("zip")
+	} else {
+		body, err = json.MarshalIndent(export, "", "  ")
+		filename += ".json"
+		c.Type("json")
+	}
+	if err != nil {
+		return serverError(c, "failed to build export", err)
+	}
+
+	if err := site.auditPrivacy(c, "export", req, counts); err != nil {
+		return serverError(c, "could not write audit log", err)
+	}
+	c.Attachment(filename)
+	return c.Send(body)
+}
+
+// AdminPrivacyErase deletes or pseudonymises everything held about an
+// e-mail address, for an erasure request
+func (site *Site) AdminPrivacyErase(c *fiber.Ctx) error {
+	req, err := parsePrivacyRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.Mode != eraseDelete && req.Mode != erasePseudonymise {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "mode must be delete or pseudonymise"})
+	}
+	// An erasure must be recorded, so none is carried out while the audit
+	// log cannot be appended to
+	if _, err := models.GetAuditLog(); err != nil {
+		return serverError(c, "could not read audit log", err)
+	}
+
+	counts := map[string]int{}
+	var failed []string
+	for _, source := range site.personalDataSources() {
+		n, err := source.erase(req.Email, req.Mode == erasePseudonymise)
+		counts[source.name] = n
+		if err != nil {
+			requestLog(c).Error("privacy: erasing failed", "source", source.name, "err", err)
+			failed = append(failed, source.name)
+		}
+	}
+
+	if err := site.auditPrivacy(c, "erase", req, counts); err != nil {
+		return serverError(c, "could not write audit log", err)
+	}
+	if len(failed) > 0 {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "erasure incomplete", "failed": failed, "counts": counts})
+	}
+	return c.JSON(fiber.Map{"counts": counts})
+}
+
+// AdminPrivacyAudit returns the log of access and erasure requests
+func AdminPrivacyAudit(c *fiber.Ctx) error {
+	entries, err := models.GetAuditLog()
+	if err != nil {
+		return serverError(c, "could not read audit log", err)
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+	return c.JSON(entries)
+}
+
+func parsePrivacyRequest(c *fiber.Ctx) (privacyRequest, error) {
+	var req privacyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return req, fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+	}
+	req.Email = models.NormalizeEmail(req.Email)
+	if req.Email == "" {
+		return req, fiber.NewError(fiber.StatusBadRequest, "email is required")
+	}
+	return req, nil
+}
+
+// auditPrivacy records a carried out request. The address is logged as a
+// pseudonym, which lookups for the same address can match.
+func (site *Site) auditPrivacy(c *fiber.Ctx, action string, req privacyRequest, counts map[string]int) error {
+	actor, _ := c.Locals("username").(string)
+	entry := &models.AuditEntry{
+		Action:  action,
+		Subject: site.emailPseudonym(req.Email),
+		Mode:    req.Mode,
+		Reason:  req.Reason,
+		Counts:  counts,
+		Actor:   actor,
+		At:      time.Now(),
+	}
+	if err := models.AppendAudit(entry); err != nil {
+		return err
+	}
+	requestLog(c).Info("privacy: request carried out", "action", action, "mode", req.Mode, "subject", entry.Subject, "audit_id", entry.ID)
+	return nil
+}
+
+// emailPseudonym is a stable keyed hash of an address, so records can be
+// told apart and matched without keeping the address
+func (site *Site) emailPseudonym(email string) string {
+	mac := hmac.New(sha256.New, site.key)
+	mac.Write([]byte("privacy:" + models.NormalizeEmail(email)))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// pseudonymousAddress replaces an erased address in records that are kept
+func (site *Site) pseudonymousAddress(email string) string {
+	return "erased-" + site.emailPseudonym(email) + "@invalid"
+}
+
+func zipExport(export personalDataExport) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	counts := map[string]int{}
+	for name, records := range export.Records {
+		counts[name] = len(records)
+		if len(records) > 0 {
+			if err := add(name+".json", records); err != nil {
+				return nil, err
+			}
+		}
+	}
+	summary := fiber.Map{"email": export.Email, "exported_at": export.ExportedAt, "counts": counts}
+	if err := add("export.json", summary); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func findSubscribers(email string) []interface{} {
+	if s := models.GetSubscriberByEmail(email); s != nil {
+		return []interface{}{*s}
+	}
+	return nil
+}
+
+func (site *Site) eraseSubscribers(email string, pseudonymise bool) (int, error) {
+	newsletterMu.Lock()
+	defer newsletterMu.Unlock()
+
+	s := models.GetSubscriberByEmail(email)
+	if s == nil {
+		return 0, nil
+	}
+	if !pseudonymise {
+		return 1, models.DeleteSubscriber(s.ID)
+	}
+	now := time.Now()
+	s.Email = site.pseudonymousAddress(email)
+	s.Name = ""
+	if s.Status != models.SubscriberUnsubscribed {
+		s.Status = models.SubscriberUnsubscribed
+		s.UnsubscribedAt = &now
+	}
+	return 1, models.SaveSubscriber(s)
+}
+
+// newsletterDelivery is a campaign sent, or to be sent, to an address
+type newsletterDelivery struct {
+	CampaignID string `json:"campaign_id"`
+	Subject    string `json:"subject"`
+	models.CampaignRecipient
+}
+
+func findNewsletterDeliveries(email string) []interface{} {
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+
+	var records []interface{}
+	for _, campaign := range models.GetAllCampaigns() {
+		for _, r := range campaign.Recipients {
+			if r.Email == email {
+				records = append(records, newsletterDelivery{CampaignID: campaign.ID, Subject: campaign.Subject, CampaignRecipient: r})
+			}
+		}
+	}
+	return records
+}
+
+// eraseNewsletterDeliveries removes or pseudonymises the address in
+// campaign recipient lists. Pending deliveries are skipped from then on.
+func (site *Site) eraseNewsletterDeliveries(email string, pseudonymise bool) (int, error) {
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+
+	n := 0
+	for _, campaign := range models.GetAllCampaigns() {
+		recipients := campaign.Recipients[:0]
+		found := 0
+		for _, r := range campaign.Recipients {
+			if r.Email != email {
+				recipients = append(recipients, r)
+				continue
+			}
+			found++
+			if pseudonymise {
+				r.Email = site.pseudonymousAddress(email)
+				r.Error = ""
+				if r.Status == models.RecipientPending {
+					r.Status = models.RecipientSkipped
+				}
+				recipients = append(recipients, r)
+			}
+		}
+		if found == 0 {
+			continue
+		}
+		campaign.Recipients = recipients
+		if err := models.SaveCampaign(&campaign); err != nil {
+			return n, err
+		}
+		n += found
+	}
+	return n, nil
+}
+
+func findQuarantined(email string) []interface{} {
+	var records []interface{}
+	for _, q := range models.GetAllQuarantined() {
+		if q.Email == email {
+			records = append(records, q)
+		}
+	}
+	return records
+}
+
+// eraseQuarantined deletes held-back submissions in either mode, as they
+// are kept only for review
+func eraseQuarantined(email string, pseudonymise bool) (int, error) {
+	n := 0
+	for _, q := range models.GetAllQuarantined() {
+		if q.Email != email {
+			continue
+		}
+		if err := models.DeleteQuarantined(q.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// outbox returns the running mail queue, or one over the same files when
+// the queue has not been started
+func outbox() *mailer.Queue {
+	if mailQueue != nil {
+		return mailQueue
+	}
+	return mailer.NewQueue(dataDir, nil)
+}
+
+func findOutgoingMail(email string) []interface{} {
+	var records []interface{}
+	for _, job := range outbox().Jobs() {
+		if job.Message.Involves(email) {
+			records = append(records, job)
+		}
+	}
+	for _, f := range outbox().Failures() {
+		if (mailer.Message{To: f.To}).Involves(email) {
+			records = append(records, f)
+		}
+	}
+	return records
+}
+
+// eraseOutgoingMail drops queued and failed e-mails to or about the address
+// in either mode
+func eraseOutgoingMail(email string, pseudonymise bool) (int, error) {
+	return outbox().Forget(email)
+}
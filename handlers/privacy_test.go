@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	privacyEmail = "soma.fan@example.org"
+	otherEmail   = "someone.else@example.org"
+)
+
+// newPrivacyApp returns an app serving the privacy endpoints over a data
+// directory holding a subscriber, campaign deliveries and a quarantined
+// submission for privacyEmail, and a subscriber for otherEmail
+func newPrivacyApp(t *testing.T) (*Site, *fiber.App) {
+	t.Helper()
+	site := newTestSite(t, nil)
+
+	now := time.Now()
+	for _, s := range []*models.Subscriber{
+		{Email: privacyEmail, Name: "Soma Fan", Status: models.SubscriberConfirmed, ConsentAt: &now, CreatedAt: now},
+		{Email: otherEmail, Status: models.SubscriberConfirmed, ConsentAt: &now, CreatedAt: now},
+	} {
+		if err := models.SaveSubscriber(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	campaign := &models.Campaign{
+		ID:      "valg",
+		Subject: "Valg",
+		Status:  models.CampaignSending,
+		Recipients: []models.CampaignRecipient{
+			{SubscriberID: "1", Email: privacyEmail, Status: models.RecipientPending},
+			{SubscriberID: "2", Email: otherEmail, Status: models.RecipientSent},
+		},
+	}
+	if err := models.SaveCampaign(campaign); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.SaveQuarantined(&models.QuarantinedSubmission{Form: "newsletter", Email: privacyEmail, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	app.Post("/privacy/lookup", site.AdminPrivacyLookup)
+	app.Post("/privacy/export", site.AdminPrivacyExport)
+	app.Post("/privacy/erase", site.AdminPrivacyErase)
+	app.Get("/privacy/audit", AdminPrivacyAudit)
+	return site, app
+}
+
+func TestAdminPrivacyExport(t *testing.T) {
+	site, app := newPrivacyApp(t)
+
+	var export personalDataExport
+	if code := request(t, app, "POST", "/privacy/export", `{"email": " Soma.Fan@example.org "}`, &export); code != fiber.StatusOK {
+		t.Fatalf("export answered %d", code)
+	}
+	if export.Email != privacyEmail {
+		t.Errorf("export for %q, want %q", export.Email, privacyEmail)
+	}
+	for name, want := range map[string]int{"newsletter_subscribers": 1, "newsletter_deliveries": 1, "form_quarantine": 1, "outgoing_mail": 0} {
+		if got := len(export.Records[name]); got != want {
+			t.Errorf("%s: %d records, want %d", name, got, want)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/privacy/export", strings.NewReader(`{"email": "soma.fan@example.org", "format": "zip"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("zip export: %v", err)
+	}
+	var files []string
+	for _, f := range zr.File {
+		files = append(files, f.Name)
+	}
+	if got := strings.Join(files, ","); !strings.Contains(got, "export.json") || !strings.Contains(got, "newsletter_subscribers.json") || strings.Contains(got, "outgoing_mail.json") {
+		t.Errorf("zip holds %s, want export.json and a file per store with records", got)
+	}
+
+	var audit []models.AuditEntry
+	request(t, app, "GET", "/privacy/audit", "", &audit)
+	if len(audit) != 2 || audit[0].Action != "export" || audit[0].Subject != site.emailPseudonym(privacyEmail) {
+		t.Errorf("audit log %+v, want two exports for the address's pseudonym", audit)
+	}
+	if data, _ := os.ReadFile("data/privacy_audit.json"); strings.Contains(string(data), privacyEmail) {
+		t.Error("the audit log holds the address itself")
+	}
+}
+
+func TestAdminPrivacyErase(t *testing.T) {
+	tests := []struct {
+		mode string
+	}{
+		{mode: eraseDelete},
+		{mode: erasePseudonymise},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			site, app := newPrivacyApp(t)
+			pseudonym := site.pseudonymousAddress(privacyEmail)
+
+			var resp struct {
+				Counts map[string]int `json:"counts"`
+			}
+			if code := request(t, app, "POST", "/privacy/erase", `{"email": "soma.fan@example.org", "mode": "`+tt.mode+`", "reason": "asked by phone"}`, &resp); code != fiber.StatusOK {
+				t.Fatalf("erase answered %d", code)
+			}
+			if resp.Counts["newsletter_subscribers"] != 1 || resp.Counts["newsletter_deliveries"] != 1 || resp.Counts["form_quarantine"] != 1 {
+				t.Errorf("counts %v, want one record in each store", resp.Counts)
+			}
+
+			if models.GetSubscriberByEmail(privacyEmail) != nil {
+				t.Error("the subscriber still has the address")
+			}
+			if models.GetSubscriberByEmail(otherEmail) == nil {
+				t.Error("the other subscriber was erased too")
+			}
+			if q := findQuarantined(privacyEmail); len(q) != 0 {
+				t.Errorf("quarantine still holds %+v", q)
+			}
+
+			kept := models.GetSubscriberByEmail(pseudonym)
+			campaign := models.GetCampaignByID("valg")
+			switch tt.mode {
+			case eraseDelete:
+				if kept != nil {
+					t.Errorf("deleting kept a pseudonymised subscriber %+v", kept)
+				}
+				if len(campaign.Recipients) != 1 || campaign.Recipients[0].Email != otherEmail {
+					t.Errorf("recipients %+v, want only the other address", campaign.Recipients)
+				}
+			case erasePseudonymise:
+				if kept == nil || kept.Name != "" || kept.Status != models.SubscriberUnsubscribed || kept.ConsentAt == nil {
+					t.Errorf("pseudonymised subscriber %+v, want it unsubscribed without a name but with its dates", kept)
+				}
+				if len(campaign.Recipients) != 2 || campaign.Recipients[0].Email != pseudonym || campaign.Recipients[0].Status != models.RecipientSkipped {
+					t.Errorf("recipients %+v, want the pending delivery pseudonymised and skipped", campaign.Recipients)
+				}
+			}
+
+			var audit []models.AuditEntry
+			request(t, app, "GET", "/privacy/audit", "", &audit)
+			if len(audit) != 1 || audit[0].Action != "erase" || audit[0].Mode != tt.mode || audit[0].Reason != "asked by phone" {
+				t.Errorf("audit log %+v, want one %s erasure", audit, tt.mode)
+			}
+		})
+	}
+}
+
+func TestAdminPrivacyEraseNeedsAuditLog(t *testing.T) {
+	_, app := newPrivacyApp(t)
+	const corrupt = `[{"id": "1"`
+	if err := os.WriteFile("data/privacy_audit.json", []byte(corrupt), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := request(t, app, "POST", "/privacy/erase", `{"email": "soma.fan@example.org", "mode": "delete"}`, nil); code != fiber.StatusInternalServerError {
+		t.Errorf("erase answered %d, want 500", code)
+	}
+	if models.GetSubscriberByEmail(privacyEmail) == nil {
+		t.Error("the subscriber was erased without an audit entry")
+	}
+	if code := request(t, app, "POST", "/privacy/export", `{"email": "soma.fan@example.org"}`, nil); code != fiber.StatusInternalServerError {
+		t.Errorf("export answered %d, want 500", code)
+	}
+	if data, _ := os.ReadFile("data/privacy_audit.json"); string(data) != corrupt {
+		t.Errorf("audit log is now %q, want it left as it was", data)
+	}
+}
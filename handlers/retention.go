@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"soma-mayel-campaign/config"
+	"soma-mayel-campaign/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// What happens to an expired record
+const (
+	retentionDelete    = "delete"
+	retentionAnonymise = "anonymise"
+)
+
+// retentionTarget is a kind of personal data with its default retention
+// rule, which RETENTION_<NAME> overrides
+type retentionTarget struct {
+	name    string
+	def     string
+	action  string
+	records func() []retentionRecord
+	purge   func(id string) error
+}
+
+func (site *Site) retentionTargets() []retentionTarget {
+	return []retentionTarget{
+		{name: "newsletter_pending", def: "30d", action: retentionDelete, records: subscriberRetentionRecords(models.SubscriberPending), purge: purgeSubscriber(models.SubscriberPending)},
+		{name: "newsletter_unsubscribed", def: "30d", action: retentionDelete, records: subscriberRetentionRecords(models.SubscriberUnsubscribed), purge: purgeSubscriber(models.SubscriberUnsubscribed)},
+		{name: "newsletter_subscribers", def: "election+30d", action: retentionDelete, records: subscriberRetentionRecords(models.SubscriberConfirmed), purge: purgeSubscriber(models.SubscriberConfirmed)},
+		{name: "newsletter_recipients", def: "election+30d", action: retentionAnonymise, records: campaignRetentionRecords, purge: site.anonymiseCampaignRecipients},
+		{name: "form_quarantine", def: "30d", action: retentionDelete, records: quarantineRetentionRecords, purge: models.DeleteQuarantined},
+		{name: "mail_failures", def: "30d", action: retentionDelete, records: mailFailureRetentionRecords, purge: func(id string) error { return outbox().RemoveFailure(id) }},
+	}
+}
+
+// retentionRecord is a record with the time its retention counts from
+type retentionRecord struct {
+	ID string    `json:"id"`
+	At time.Time `json:"at"`
+}
+
+// retentionRule says when records expire: once they are older than maxAge,
+// and all of them after deadline. A rule is written as a comma-separated
+// list of ages such as "90d" or "720h" and deadlines such as "election" or
+// "election+30d"; "keep" keeps records forever.
+type retentionRule struct {
+	maxAge   time.Duration
+	deadline time.Time
+}
+
+// retentionPlan is what a retention run does to one kind of data
+type retentionPlan struct {
+	Name    string            `json:"name"`
+	Rule    string            `json:"rule"`
+	Action  string            `json:"action"`
+	Error   string            `json:"error,omitempty"`
+	Total   int               `json:"total"`
+	Expired []retentionRecord `json:"expired"`
+}
+
+// StartRetention purges or anonymises expired personal data now and every
+// RETENTION_INTERVAL (default 24h) until ctx is cancelled. It does nothing
+// unless RETENTION_MODE is on; with dry-run it only logs what it would
+// remove.
+func (site *Site) StartRetention(ctx context.Context) {
+	mode := site.cfg.Storage.RetentionMode
+	if mode == config.RetentionOff {
+		slog.Info("retention: off, set RETENTION_MODE to dry-run or on")
+		return
+	}
+	interval := site.cfg.Storage.RetentionInterval
+
+	goWorker(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			site.applyRetention(time.Now(), mode == config.RetentionDryRun)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// AdminRetentionReport is a dry run: it lists the records the next run
+// would remove, without changing anything
+func (site *Site) AdminRetentionReport(c *fiber.Ctx) error {
+	election, err := site.electionDate()
+	report := fiber.Map{
+		"mode":         site.cfg.Storage.RetentionMode,
+		"generated_at": time.Now(),
+		"targets":      site.planRetention(time.Now()),
+	}
+	if err != nil {
+		report["election_error"] = err.Error()
+	} else {
+		report["election_date"] = election.Format("2006-01-02")
+	}
+	return c.JSON(report)
+}
+
+// planRetention finds the expired records of every kind of data
+func (site *Site) planRetention(now time.Time) []retentionPlan {
+	election, electionErr := site.electionDate()
+
+	targets := site.retentionTargets()
+	plans := make([]retentionPlan, 0, len(targets))
+	for _, target := range targets {
+		spec := site.cfg.Storage.Retention[target.name]
+		if spec == "" {
+			spec = target.def
+		}
+		plan := retentionPlan{Name: target.name, Rule: spec, Action: target.action, Expired: []retentionRecord{}}
+
+		rule, err := parseRetentionRule(spec, election)
+		if err == nil && electionErr != nil && strings.Contains(spec, "election") {
+			err = electionErr
+		}
+		if err != nil {
+			plan.Error = err.Error()
+			plans = append(plans, plan)
+			continue
+		}
+
+		records := target.records()
+		plan.Total = len(records)
+		for _, r := range records {
+			if rule.expired(r.At, now) {
+				plan.Expired = append(plan.Expired, r)
+			}
+		}
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// applyRetention purges or anonymises expired records and logs each one.
+// A dry run only logs how many records each rule would remove.
+func (site *Site) applyRetention(now time.Time, dryRun bool) {
+	targets := site.retentionTargets()
+	plans := site.planRetention(now)
+	for i, plan := range plans {
+		if plan.Error != "" {
+			slog.Warn("retention: skipped, invalid rule", "target", plan.Name, "rule", plan.Rule, "err", plan.Error)
+			continue
+		}
+		if dryRun {
+			if len(plan.Expired) > 0 {
+				slog.Info("retention: dry run, would "+plan.Action+" expired records", "target", plan.Name, "count", len(plan.Expired), "total", plan.Total, "rule", plan.Rule)
+			}
+			continue
+		}
+		done := 0
+		for _, r := range plan.Expired {
+			if err := targets[i].purge(r.ID); err != nil {
+				slog.Error("retention: could not "+plan.Action+" record", "target", plan.Name, "record_id", r.ID, "err", err)
+				continue
+			}
+			slog.Info("retention: "+plan.Action+"d record", "target", plan.Name, "record_id", r.ID, "from", r.At.Format("2006-01-02"))
+			done++
+		}
+		if done > 0 {
+			slog.Info("retention: "+plan.Action+"d expired records", "target", plan.Name, "count", done, "total", plan.Total, "rule", plan.Rule)
+		}
+	}
+}
+
+// electionDate returns ELECTION_DATE (YYYY-MM-DD), which rules count from
+func (site *Site) electionDate() (time.Time, error) {
+	if site.cfg.Storage.ElectionDate.IsZero() {
+		return time.Time{}, fmt.Errorf("ELECTION_DATE is not set")
+	}
+	return site.cfg.Storage.ElectionDate, nil
+}
+
+func parseRetentionRule(spec string, election time.Time) (retentionRule, error) {
+	var rule retentionRule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "keep":
+		case strings.HasPrefix(part, "election"):
+			offset := time.Duration(0)
+			if rest := strings.TrimPrefix(part, "election"); rest != "" {
+				d, err := parseRetentionAge(strings.TrimPrefix(rest, "+"))
+				if err != nil || (rest[0] != '+' && rest[0] != '-') {
+					return rule, fmt.Errorf("invalid election offset %q", rest)
+				}
+				offset = d
+			}
+			rule.deadline = election.Add(offset)
+		default:
+			d, err := parseRetentionAge(part)
+			if err != nil || d <= 0 {
+				return rule, fmt.Errorf("invalid age %q", part)
+			}
+			rule.maxAge = d
+		}
+	}
+	return rule, nil
+}
+
+// parseRetentionAge accepts days such as "90d" or a Go duration
+func parseRetentionAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		return time.Duration(days) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(s)
+}
+
+func (r retentionRule) expired(at, now time.Time) bool {
+	if at.IsZero() {
+		return false
+	}
+	if r.maxAge > 0 && now.Sub(at) > r.maxAge {
+		return true
+	}
+	return !r.deadline.IsZero() && now.After(r.deadline)
+}
+
+// subscriberRetentionTime is when a subscriber last signed up, consented
+// or unsubscribed, depending on their status
+func subscriberRetentionTime(s models.Subscriber) time.Time {
+	switch {
+	case s.Status == models.SubscriberConfirmed && s.ConsentAt != nil:
+		return *s.ConsentAt
+	case s.Status == models.SubscriberUnsubscribed && s.UnsubscribedAt != nil:
+		return *s.UnsubscribedAt
+	case s.Status == models.SubscriberPending && s.ConfirmSentAt != nil:
+		return *s.ConfirmSentAt
+	}
+	return s.CreatedAt
+}
+
+func subscriberRetentionRecords(status string) func() []retentionRecord {
+	return func() []retentionRecord {
+		var records []retentionRecord
+		for _, s := range models.GetAllSubscribers() {
+			if s.Status == status {
+				records = append(records, retentionRecord{ID: s.ID, At: subscriberRetentionTime(s)})
+			}
+		}
+		return records
+	}
+}
+
+// purgeSubscriber deletes a subscriber unless their status changed since
+// the plan was made
+func purgeSubscriber(status string) func(id string) error {
+	return func(id string) error {
+		newsletterMu.Lock()
+		defer newsletterMu.Unlock()
+		s := models.GetSubscriberByID(id)
+		if s == nil || s.Status != status {
+			return fmt.Errorf("subscriber is no longer %s", status)
+		}
+		return models.DeleteSubscriber(id)
+	}
+}
+
+// campaignRetentionRecords are the sent campaigns whose recipient lists
+// still hold addresses
+func campaignRetentionRecords() []retentionRecord {
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+
+	var records []retentionRecord
+	for _, campaign := range models.GetAllCampaigns() {
+		if campaign.Status != models.CampaignSent || campaign.FinishedAt == nil {
+			continue
+		}
+		for _, r := range campaign.Recipients {
+			if !strings.HasSuffix(r.Email, "@invalid") {
+				records = append(records, retentionRecord{ID: campaign.ID, At: *campaign.FinishedAt})
+				break
+			}
+		}
+	}
+	return records
+}
+
+// anonymiseCampaignRecipients replaces the addresses of a sent campaign's
+// recipients with pseudonyms, keeping the delivery statistics
+func (site *Site) anonymiseCampaignRecipients(id string) error {
+	campaignMu.Lock()
+	defer campaignMu.Unlock()
+
+	campaign := models.GetCampaignByID(id)
+	if campaign == nil {
+		return fmt.Errorf("campaign not found")
+	}
+	for i := range campaign.Recipients {
+		r := &campaign.Recipients[i]
+		if !strings.HasSuffix(r.Email, "@invalid") {
+			r.Email = site.pseudonymousAddress(r.Email)
+		}
+		r.SubscriberID = ""
+		r.Error = ""
+	}
+	return models.SaveCampaign(campaign)
+}
+
+func quarantineRetentionRecords() []retentionRecord {
+	var records []retentionRecord
+	for _, q := range models.GetAllQuarantined() {
+		records = append(records, retentionRecord{ID: q.ID, At: q.CreatedAt})
+	}
+	return records
+}
+
+func mailFailureRetentionRecords() []retentionRecord {
+	var records []retentionRecord
+	for _, f := range outbox().Failures() {
+		records = append(records, retentionRecord{ID: f.ID, At: f.FailedAt})
+	}
+	return records
+}
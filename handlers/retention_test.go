@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"soma-mayel-campaign/config"
+)
+
+func TestParseRetentionRule(t *testing.T) {
+	election := time.Date(2026, 11, 17, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		spec    string
+		want    retentionRule
+		wantErr bool
+	}{
+		{"30d", retentionRule{maxAge: 30 * day}, false},
+		{"720h", retentionRule{maxAge: 720 * time.Hour}, false},
+		{"keep", retentionRule{}, false},
+		{"election", retentionRule{deadline: election}, false},
+		{"election+30d", retentionRule{deadline: election.Add(30 * day)}, false},
+		{"election-7d", retentionRule{deadline: election.Add(-7 * day)}, false},
+		{"90d, election+30d", retentionRule{maxAge: 90 * day, deadline: election.Add(30 * day)}, false},
+		{"0d", retentionRule{}, true},
+		{"-5d", retentionRule{}, true},
+		{"30 days", retentionRule{}, true},
+		{"election30d", retentionRule{}, true},
+		{"election+soon", retentionRule{}, true},
+		{"forever", retentionRule{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRetentionRule(tt.spec, election)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRetentionRule(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseRetentionRule(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestRetentionRuleExpired(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name string
+		rule retentionRule
+		at   time.Time
+		want bool
+	}{
+		{"younger than max age", retentionRule{maxAge: 30 * day}, now.Add(-29 * day), false},
+		{"older than max age", retentionRule{maxAge: 30 * day}, now.Add(-31 * day), true},
+		{"before deadline", retentionRule{deadline: now.Add(day)}, now.Add(-365 * day), false},
+		{"after deadline", retentionRule{deadline: now.Add(-day)}, now.Add(-time.Hour), true},
+		{"either expires", retentionRule{maxAge: 30 * day, deadline: now.Add(day)}, now.Add(-31 * day), true},
+		{"keep", retentionRule{}, now.Add(-3650 * day), false},
+		{"no timestamp", retentionRule{maxAge: day, deadline: now.Add(-day)}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.expired(tt.at, now); got != tt.want {
+			t.Errorf("%s: expired = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanRetention(t *testing.T) {
+	cfg := config.Default()
+	cfg.Storage.Retention = map[string]string{"form_quarantine": "soon", "mail_failures": "keep"}
+	site := newTestSite(t, cfg)
+
+	plans := map[string]retentionPlan{}
+	for _, plan := range site.planRetention(time.Now()) {
+		plans[plan.Name] = plan
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		wantErr string
+	}{
+		{"newsletter_pending", "30d", ""},
+		{"newsletter_subscribers", "election+30d", "ELECTION_DATE is not set"},
+		{"form_quarantine", "soon", `invalid age "soon"`},
+		{"mail_failures", "keep", ""},
+	}
+	for _, tt := range tests {
+		plan, ok := plans[tt.name]
+		if !ok {
+			t.Errorf("no plan for %s", tt.name)
+			continue
+		}
+		if plan.Rule != tt.rule || plan.Error != tt.wantErr {
+			t.Errorf("%s: rule %q, error %q, want %q, %q", tt.name, plan.Rule, plan.Error, tt.rule, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// appSecretFile holds the generated signing secret when APP_SECRET is unset
+const appSecretFile = dataDir + "/app_secret"
+
+var errBadToken = errors.New("invalid_token")
+
+// signingKey returns secret, or a random secret generated on first start
+// and kept in the data directory so links survive restarts
+func signingKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	if data, err := ioutil.ReadFile(appSecretFile); err == nil && len(data) > 0 {
+		return data
+	}
+	b := make([]byte, 32)
+	rand.Read(b)
+	key := []byte(hex.EncodeToString(b))
+	err := os.MkdirAll(filepath.Dir(appSecretFile), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(appSecretFile, key, 0600)
+	}
+	if err != nil {
+		slog.Error("could not store signing secret, links will break on restart", "err", err)
+	}
+	return key
+}
+
+// signValue returns a URL-safe token binding value to purpose. A zero
+// expires means the token never expires.
+func (site *Site) signValue(purpose, value string, expires time.Time) string {
+	var exp int64
+	if !expires.IsZero() {
+		exp = expires.Unix()
+	}
+	payload := value + "." + strconv.FormatInt(exp, 36)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + site.signature(purpose, payload)
+}
+
+// verifySigned returns the value in a token made by signValue for purpose
+func (site *Site) verifySigned(purpose, token string) (string, error) {
+	dot := strings.LastIndex(token, ".")
+	if dot < 0 {
+		return "", errBadToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token[:dot])
+	if err != nil {
+		return "", errBadToken
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(token[dot+1:]), []byte(site.signature(purpose, payload))) {
+		return "", errBadToken
+	}
+
+	sep := strings.LastIndex(payload, ".")
+	if sep < 0 {
+		return "", errBadToken
+	}
+	exp, err := strconv.ParseInt(payload[sep+1:], 36, 64)
+	if err != nil {
+		return "", errBadToken
+	}
+	if exp != 0 && time.Now().Unix() > exp {
+		return "", errBadToken
+	}
+	return payload[:sep], nil
+}
+
+func (site *Site) signature(purpose, payload string) string {
+	mac := hmac.New(sha256.New, site.key)
+	mac.Write([]byte(purpose + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
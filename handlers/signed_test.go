@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySigned(t *testing.T) {
+	site := newTestSite(t, nil)
+	other := New(site.cfg, nil)
+	other.key = []byte("another-secret")
+
+	valid := site.signValue("confirm", "sub_1.with.dots", time.Now().Add(time.Hour))
+	body, sig, _ := strings.Cut(valid, ".")
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{"valid", "confirm", valid, "sub_1.with.dots", false},
+		{"never expires", "confirm", site.signValue("confirm", "sub_2", time.Time{}), "sub_2", false},
+		{"empty value", "confirm", site.signValue("confirm", "", time.Time{}), "", false},
+		{"other purpose", "unsubscribe", valid, "", true},
+		{"expired", "confirm", site.signValue("confirm", "sub_1", time.Now().Add(-time.Second)), "", true},
+		{"other key", "confirm", other.signValue("confirm", "sub_1", time.Time{}), "", true},
+		{"changed value", "confirm", base64.RawURLEncoding.EncodeToString([]byte("sub_9.0")) + "." + sig, "", true},
+		{"changed signature", "confirm", body + "." + strings.ToUpper(sig), "", true},
+		{"no signature", "confirm", body, "", true},
+		{"not base64", "confirm", "!!!." + sig, "", true},
+		{"empty", "confirm", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := site.verifySigned(tt.purpose, tt.token)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("verifySigned(%q) = %q, %v, want %q, error %v", tt.token, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	newTestSite(t, nil)
+
+	if got := string(signingKey("configured")); got != "configured" {
+		t.Errorf("signingKey with a secret = %q", got)
+	}
+	generated := signingKey("")
+	if len(generated) != 64 {
+		t.Errorf("generated key has %d characters, want 64", len(generated))
+	}
+	if again := signingKey(""); string(again) != string(generated) {
+		t.Error("generated key was not kept for the next start")
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"io/fs"
+	"sync"
+
+	"soma-mayel-campaign/config"
+	"soma-mayel-campaign/facebook"
+	mailer "soma-mayel-campaign/mail"
+)
+
+// Site holds what the handlers and background workers run with: the
+// configuration and the templates. main creates one with New and registers
+// its methods as routes.
+type Site struct {
+	cfg       *config.Config
+	templates fs.FS // e-mail templates under email/, such as the copy embedded in the binary
+	key       []byte
+
+	templatesLoaded bool
+
+	sourcesOnce sync.Once
+	sources     []*socialSource
+}
+
+// New returns the handlers for cfg, reading e-mail templates from templates
+func New(cfg *config.Config, templates fs.FS) *Site {
+	return &Site{cfg: cfg, templates: templates, key: signingKey(cfg.Server.AppSecret)}
+}
+
+// TemplatesLoaded records that the page templates parsed, which /readyz
+// reports
+func (site *Site) TemplatesLoaded() {
+	site.templatesLoaded = true
+}
+
+// MailTransport is the configured way of sending e-mail
+func (site *Site) MailTransport() mailer.Transport {
+	m := site.cfg.Mail
+	return mailer.NewTransport(m.Transport, m.DropDir, site.smtpTransport())
+}
+
+// smtpTransport is the configured SMTP server
+func (site *Site) smtpTransport() mailer.SMTP {
+	m := site.cfg.Mail
+	return mailer.SMTP{
+		Host:     m.SMTPHost,
+		Port:     m.SMTPPort,
+		Username: m.SMTPUser,
+		Password: m.SMTPPassword,
+		From:     m.From,
+	}
+}
+
+// newGraphClient returns a Graph API client with the configured app and
+// the access token from the environment, if any
+func (site *Site) newGraphClient() *facebook.GraphClient {
+	fb := site.cfg.Facebook
+	c := facebook.NewClient(fb.GraphURL, fb.GraphVersion, fb.AccessToken)
+	c.AppID = fb.AppID
+	c.AppSecret = fb.AppSecret
+	return c
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/facebook"
+	"soma-mayel-campaign/jsonfile"
+	"soma-mayel-campaign/social"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// socialFeedTTL is how long fetched items count as fresh
+	socialFeedTTL = 5 * time.Minute
+	// socialRetryDelay is how long to wait after a failed fetch
+	socialRetryDelay = 2 * time.Minute
+	// socialRateLimitDelay is how long to back off when a source throttles us
+	socialRateLimitDelay = 15 * time.Minute
+)
+
+// socialSource holds the last good items of one provider. Requests are
+// always answered from it; fetching happens in the background, one refresh
+// at a time per source.
+type socialSource struct {
+	provider  social.Provider
+	limit     int
+	cacheFile string
+
+	mu          sync.Mutex
+	items       []social.Item
+	fetchedAt   time.Time
+	lastErr     string
+	nextAttempt time.Time
+	refreshing  bool
+}
+
+// socialSnapshot is a source's last good items as persisted to disk
+type socialSnapshot struct {
+	Items     []social.Item `json:"items"`
+	FetchedAt time.Time     `json:"fetched_at"`
+}
+
+// socialSourceStatus describes how current a source's items are
+type socialSourceStatus struct {
+	Cached         bool       `json:"cached"`
+	Stale          bool       `json:"stale"`
+	FetchedAt      *time.Time `json:"fetched_at,omitempty"`
+	FallbackReason string     `json:"fallback_reason,omitempty"`
+	Count          int        `json:"count"`
+}
+
+type socialFeedResponse struct {
+	Items   []social.Item                 `json:"items"`
+	Sources map[string]socialSourceStatus `json:"sources"`
+}
+
+// SocialFeed returns the cached items of all sources merged newest first.
+// ?source=facebook,instagram limits the sources and ?limit= the number of
+// items.
+func (site *Site) SocialFeed(c *fiber.Ctx) error {
+	sources := site.socialSources()
+
+	if filter := strings.TrimSpace(c.Query("source")); filter != "" {
+		selected := make([]*socialSource, 0, len(sources))
+		for _, name := range strings.Split(filter, ",") {
+			src := site.findSocialSource(strings.TrimSpace(name))
+			if src == nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown source: " + name})
+			}
+			selected = append(selected, src)
+		}
+		sources = selected
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	now := time.Now()
+	resp := socialFeedResponse{Sources: map[string]socialSourceStatus{}}
+	lists := make([][]social.Item, 0, len(sources))
+	for _, src := range sources {
+		src.trigger()
+		items, status := src.snapshot(now)
+		lists = append(lists, items)
+		resp.Sources[src.provider.Name()] = status
+	}
+
+	resp.Items = social.Merge(lists...)
+	if len(resp.Items) > limit {
+		resp.Items = resp.Items[:limit]
+	}
+	if resp.Items == nil {
+		resp.Items = []social.Item{}
+	}
+	return c.JSON(resp)
+}
+
+// StartSocialRefresher loads the persisted items of every source and keeps
+// them, and the Facebook token check, up to date in the background until
+// ctx is cancelled
+func (site *Site) StartSocialRefresher(ctx context.Context) {
+	for _, src := range site.socialSources() {
+		src.trigger()
+	}
+	site.triggerFacebookTokenCheck()
+
+	goWorker(func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			for _, src := range site.socialSources() {
+				src.trigger()
+			}
+			site.triggerFacebookTokenCheck()
+		}
+	})
+}
+
+// socialSources returns the configured sources, creating them on first use:
+// Facebook always, Instagram when INSTAGRAM_USER_ID is set, and one feed per
+// name=url entry in RSS_FEEDS
+func (site *Site) socialSources() []*socialSource {
+	site.sourcesOnce.Do(func() {
+		providers := []social.Provider{
+			&social.Facebook{Client: site.facebookClient, PageID: site.facebookPageID()},
+		}
+		if userID := site.cfg.Social.InstagramUserID; userID != "" {
+			providers = append(providers, &social.Instagram{Client: site.instagramClient, UserID: userID})
+		}
+		// Feeds cannot take the names of the built-in sources, even when
+		// Instagram is off, nor share one, as the name picks the cache file
+		taken := map[string]bool{"facebook": true, "instagram": true}
+		for _, feed := range site.cfg.Social.RSSFeeds {
+			name := sourceName(feed.Name)
+			if name == "" {
+				slog.Warn("social: ignoring RSS_FEEDS entry without a usable name", "name", feed.Name)
+				continue
+			}
+			if taken[name] {
+				slog.Warn("social: ignoring RSS_FEEDS entry whose name is already used", "name", feed.Name)
+				continue
+			}
+			taken[name] = true
+			providers = append(providers, social.NewFeed(name, feed.URL))
+		}
+
+		for _, p := range providers {
+			src := &socialSource{
+				provider:  p,
+				limit:     site.cfg.Social.FeedLimit,
+				cacheFile: dataDir + "/social_" + p.Name() + ".json",
+			}
+			if p.Name() == "facebook" {
+				src.limit = site.cfg.Facebook.FeedLimit
+			}
+			src.load()
+			site.sources = append(site.sources, src)
+		}
+	})
+	return site.sources
+}
+
+func (site *Site) findSocialSource(name string) *socialSource {
+	for _, src := range site.socialSources() {
+		if src.provider.Name() == name {
+			return src
+		}
+	}
+	return nil
+}
+
+var sourceNamePattern = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// sourceName normalises a configured feed name for use in filters and file
+// names
+func sourceName(s string) string {
+	return strings.Trim(sourceNamePattern.ReplaceAllString(strings.ToLower(strings.TrimSpace(s)), "-"), "-")
+}
+
+func (s *socialSource) snapshot(now time.Time) ([]social.Item, socialSourceStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fetchedAt.IsZero() {
+		reason := s.lastErr
+		if reason == "" {
+			reason = "not_fetched_yet"
+		}
+		socialCacheRequests.WithLabelValues(s.provider.Name(), "empty").Inc()
+		return []social.Item{}, socialSourceStatus{FallbackReason: reason}
+	}
+
+	fetchedAt := s.fetchedAt
+	status := socialSourceStatus{
+		Cached:    true,
+		Stale:     now.Sub(fetchedAt) > socialFeedTTL,
+		FetchedAt: &fetchedAt,
+		Count:     len(s.items),
+	}
+	if status.Stale {
+		status.FallbackReason = s.lastErr
+		socialCacheRequests.WithLabelValues(s.provider.Name(), "stale").Inc()
+	} else {
+		socialCacheRequests.WithLabelValues(s.provider.Name(), "fresh").Inc()
+	}
+	items := s.items
+	if items == nil {
+		items = []social.Item{}
+	}
+	return items, status
+}
+
+func (s *socialSource) lastError() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// reset makes the next trigger fetch straight away, e.g. after the access
+// token changed
+func (s *socialSource) reset() {
+	s.mu.Lock()
+	s.nextAttempt = time.Time{}
+	s.mu.Unlock()
+	s.trigger()
+}
+
+// trigger starts a background fetch unless one is already running or the
+// next attempt is not due yet
+func (s *socialSource) trigger() {
+	s.mu.Lock()
+	if s.refreshing || time.Now().Before(s.nextAttempt) {
+		s.mu.Unlock()
+		return
+	}
+	s.refreshing = true
+	s.mu.Unlock()
+
+	if !goWorker(s.refresh) {
+		s.mu.Lock()
+		s.refreshing = false
+		s.mu.Unlock()
+	}
+}
+
+// refresh fetches the source's items, giving up when shutdown starts
+func (s *socialSource) refresh() {
+	shutdown := shutdownContext()
+	ctx, cancel := context.WithTimeout(shutdown, 30*time.Second)
+	items, err := s.provider.Fetch(ctx, s.limit)
+	cancel()
+	now := time.Now()
+	socialFetches.WithLabelValues(s.provider.Name(), socialFetchResult(err)).Inc()
+
+	s.mu.Lock()
+	s.refreshing = false
+	if err != nil {
+		reason, delay := socialFailure(err)
+		s.lastErr = reason
+		s.nextAttempt = now.Add(delay)
+		s.mu.Unlock()
+		if err != facebook.ErrNoAccessToken && shutdown.Err() == nil {
+			slog.Warn("social: refresh failed, serving cached items", "source", s.provider.Name(), "err", err)
+		}
+		return
+	}
+	s.items = items
+	s.fetchedAt = now
+	s.lastErr = ""
+	s.nextAttempt = now.Add(socialFeedTTL)
+	s.mu.Unlock()
+
+	if err := jsonfile.Write(s.cacheFile, socialSnapshot{Items: items, FetchedAt: now}, 0644); err != nil {
+		slog.Error("social: could not persist items", "source", s.provider.Name(), "err", err)
+	}
+}
+
+// load restores the last good items saved by a previous run
+func (s *socialSource) load() {
+	var snap socialSnapshot
+	if err := jsonfile.Read(s.cacheFile, &snap); err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("social: ignoring unreadable cache", "source", s.provider.Name(), "file", s.cacheFile, "err", err)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	s.items = snap.Items
+	s.fetchedAt = snap.FetchedAt
+	s.mu.Unlock()
+}
+
+// socialFailure returns the reason reported to clients for a failed fetch
+// and how long to wait before trying again
+func socialFailure(err error) (string, time.Duration) {
+	switch {
+	case err == facebook.ErrNoAccessToken:
+		return err.Error(), socialRetryDelay
+	case facebook.IsTokenExpired(err):
+		return "token_expired", socialRetryDelay
+	case facebook.IsRateLimited(err):
+		return "rate_limited", socialRateLimitDelay
+	default:
+		return err.Error(), socialRetryDelay
+	}
+}
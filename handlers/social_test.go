@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"soma-mayel-campaign/config"
+)
+
+func TestSocialSourceNames(t *testing.T) {
+	cfg := config.Default()
+	cfg.Social.RSSFeeds = []config.RSSFeed{
+		{Name: "Radikale Fredensborg", URL: "https://example.org/rss"},
+		{Name: "Facebook", URL: "https://example.org/fb.xml"},
+		{Name: "instagram", URL: "https://example.org/ig.xml"},
+		{Name: "radikale fredensborg", URL: "https://example.org/other.xml"},
+		{Name: "!!!", URL: "https://example.org/none.xml"},
+	}
+	site := newTestSite(t, cfg)
+
+	var names []string
+	for _, src := range site.socialSources() {
+		names = append(names, src.provider.Name())
+	}
+	if want := []string{"facebook", "radikale-fredensborg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sources %v, want %v", names, want)
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"soma-mayel-campaign/models"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,9 +19,12 @@ func TinaContentAPI(c *fiber.Ctx) error {
 
 	// Save content to database/file system
 	if err := models.SaveContent(content); err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to save content",
-		})
+		if errors.Is(err, models.ErrInvalidContent) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Unknown collection or invalid id",
+			})
+		}
+		return serverError(c, "Failed to save content", err)
 	}
 
 	return c.JSON(fiber.Map{
@@ -32,7 +36,12 @@ func TinaContentAPI(c *fiber.Ctx) error {
 // TinaGetContent retrieves content for TinaCMS
 func TinaGetContent(c *fiber.Ctx) error {
 	collection := c.Params("collection")
-	
+	if !models.IsContentCollection(collection) {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Content not found",
+		})
+	}
+
 	content, err := models.GetContentByCollection(collection)
 	if err != nil {
 		return c.Status(404).JSON(fiber.Map{
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+)
+
+// workers tracks the goroutines started by the Start functions and the
+// fetches they trigger, so shutdown can wait for them to finish what they
+// are doing
+var workers struct {
+	mu sync.Mutex
+	wg sync.WaitGroup
+	// ctx is cancelled when shutdown starts; see StartWorkers
+	ctx context.Context
+	// stopped is set by WaitForWorkers, after which no worker starts
+	stopped bool
+}
+
+// StartWorkers sets the context, cancelled when shutdown starts, that work
+// triggered by requests runs under. No worker starts once it is cancelled.
+// Call it before the Start functions.
+func StartWorkers(ctx context.Context) {
+	workers.mu.Lock()
+	defer workers.mu.Unlock()
+	workers.ctx = ctx
+}
+
+// shutdownContext returns the context set by StartWorkers
+func shutdownContext() context.Context {
+	workers.mu.Lock()
+	defer workers.mu.Unlock()
+	if workers.ctx == nil {
+		return context.Background()
+	}
+	return workers.ctx
+}
+
+// goWorker runs f in a tracked goroutine. Once shutdown has started it does
+// not run f and reports false.
+func goWorker(f func()) bool {
+	workers.mu.Lock()
+	if workers.stopped || (workers.ctx != nil && workers.ctx.Err() != nil) {
+		workers.mu.Unlock()
+		return false
+	}
+	workers.wg.Add(1)
+	workers.mu.Unlock()
+
+	go func() {
+		defer workers.wg.Done()
+		f()
+	}()
+	return true
+}
+
+// WaitForWorkers waits until the background workers have stopped after the
+// context passed to their Start functions was cancelled. It gives up when
+// ctx is done.
+func WaitForWorkers(ctx context.Context) error {
+	workers.mu.Lock()
+	workers.stopped = true
+	workers.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		workers.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGoWorkerStopsAtShutdown(t *testing.T) {
+	newTestSite(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartWorkers(ctx)
+
+	ran := make(chan context.Context, 1)
+	if !goWorker(func() { ran <- shutdownContext() }) {
+		t.Fatal("goWorker refused work before shutdown")
+	}
+	workerCtx := <-ran
+
+	cancel()
+	if workerCtx.Err() == nil {
+		t.Error("work triggered by a request was not cancelled by shutdown")
+	}
+	if goWorker(func() { t.Error("worker ran after shutdown started") }) {
+		t.Error("goWorker accepted work after shutdown started")
+	}
+
+	stop, stopCancel := context.WithTimeout(context.Background(), time.Second)
+	defer stopCancel()
+	if err := WaitForWorkers(stop); err != nil {
+		t.Fatal(err)
+	}
+	StartWorkers(context.Background())
+	if goWorker(func() { t.Error("worker ran after WaitForWorkers") }) {
+		t.Error("goWorker accepted work after WaitForWorkers")
+	}
+}
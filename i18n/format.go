@@ -0,0 +1,127 @@
+package i18n
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatDate formats t as a long date in the given locale, e.g.
+// "2. januar 2006" in Danish
+func FormatDate(locale string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	day := FormatNumber(locale, t.Day())
+	month := T(locale, "month."+strconv.Itoa(int(t.Month())))
+	year := localizeDigits(locale, strconv.Itoa(t.Year()))
+	return T(locale, "date.long", day, month, year)
+}
+
+// FormatNumber formats an integer or float with the locale's digit
+// grouping, decimal separator and digits. Floats are rounded to at most two
+// decimals.
+func FormatNumber(locale string, n interface{}) string {
+	var s string
+	switch v := n.(type) {
+	case int:
+		s = strconv.Itoa(v)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	case float64:
+		s = strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+	case float32:
+		s = strconv.FormatFloat(math.Round(float64(v)*100)/100, 'f', -1, 64)
+	default:
+		return ""
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+
+	// Group thousands
+	group := T(locale, "number.group")
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(r)
+	}
+	out := sign + b.String()
+	if fracPart != "" {
+		out += T(locale, "number.decimal") + fracPart
+	}
+	return localizeDigits(locale, out)
+}
+
+// TimeAgo describes t relative to now in the given locale, e.g.
+// "for 3 dage siden" in Danish or "in 2 hours" for future times
+func TimeAgo(locale string, t time.Time) string {
+	return relativeTime(locale, t, time.Now())
+}
+
+func relativeTime(locale string, t, now time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	if d < time.Minute {
+		return T(locale, "time.just_now")
+	}
+
+	var unit string
+	var count int
+	switch {
+	case d < time.Hour:
+		unit, count = "minute", int(d/time.Minute)
+	case d < 24*time.Hour:
+		unit, count = "hour", int(d/time.Hour)
+	case d < 7*24*time.Hour:
+		unit, count = "day", int(d/(24*time.Hour))
+	case d < 30*24*time.Hour:
+		unit, count = "week", int(d/(7*24*time.Hour))
+	case d < 365*24*time.Hour:
+		unit, count = "month", int(d/(30*24*time.Hour))
+	default:
+		unit, count = "year", int(d/(365*24*time.Hour))
+	}
+
+	plural := "other"
+	if count == 1 {
+		plural = "one"
+	}
+	amount := T(locale, "time."+unit+"."+plural, FormatNumber(locale, count))
+	if future {
+		return T(locale, "time.in", amount)
+	}
+	return T(locale, "time.ago", amount)
+}
+
+// localizeDigits replaces ASCII digits with the locale's native digits,
+// configured as a ten character string under "number.digits"
+func localizeDigits(locale, s string) string {
+	digits := []rune(T(locale, "number.digits"))
+	if len(digits) != 10 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return digits[r-'0']
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      interface{}
+		want   string
+	}{
+		{"da", 7, "7"},
+		{"da", 1234567, "1.234.567"},
+		{"da", -1234, "-1.234"},
+		{"da", 1234.5, "1.234,5"},
+		{"da", 2.345, "2,35"},
+		{"en", 1234567, "1,234,567"},
+		{"en", 1234.5, "1,234.5"},
+		{"en", int64(1000), "1,000"},
+		{"fa", 1234, "۱٬۲۳۴"},
+		{"fa", 12.5, "۱۲٫۵"},
+		{"en", "12", ""},
+	}
+	for _, tt := range tests {
+		if got := FormatNumber(tt.locale, tt.n); got != tt.want {
+			t.Errorf("FormatNumber(%q, %v) = %q, want %q", tt.locale, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		locale string
+		ago    time.Duration
+		want   string
+	}{
+		{"da", 30 * time.Second, "lige nu"},
+		{"da", time.Minute, "for 1 minut siden"},
+		{"da", 5 * time.Minute, "for 5 minutter siden"},
+		{"da", 3 * 24 * time.Hour, "for 3 dage siden"},
+		{"da", -2 * time.Hour, "om 2 timer"},
+		{"en", time.Hour, "1 hour ago"},
+		{"en", 2 * time.Hour, "2 hours ago"},
+		{"en", 8 * 24 * time.Hour, "1 week ago"},
+		{"en", 400 * 24 * time.Hour, "1 year ago"},
+		{"en", -24 * time.Hour, "in 1 day"},
+		{"fa", 3 * 24 * time.Hour, "۳ روز پیش"},
+	}
+	for _, tt := range tests {
+		if got := relativeTime(tt.locale, now.Add(-tt.ago), now); got != tt.want {
+			t.Errorf("relativeTime(%q, now-%v) = %q, want %q", tt.locale, tt.ago, got, tt.want)
+		}
+	}
+	if got := relativeTime("en", time.Time{}, now); got != "" {
+		t.Errorf("relativeTime of the zero time = %q, want empty", got)
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	d := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"da", "2. januar 2026"},
+		{"en", "2 January 2026"},
+		{"fa", "۲ جنوری ۲۰۲۶"},
+	}
+	for _, tt := range tests {
+		if got := FormatDate(tt.locale, d); got != tt.want {
+			t.Errorf("FormatDate(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
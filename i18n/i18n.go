@@ -0,0 +1,113 @@
+// Package i18n holds the site's supported locales, the translation
+// catalogues for template strings and the middleware that picks the locale
+// for a request.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Locale describes one of the languages the site is published in
+type Locale struct {
+	Code     string // URL prefix and catalogue file name
+	Name     string // Native name shown in the language switcher
+	HrefLang string // Value for hreflang and the html lang attribute
+	Dir      string // Text direction, "ltr" or "rtl"
+}
+
+// Default is the locale served without a URL prefix
+const Default = "da"
+
+// Locales lists the supported locales, default first
+var Locales = []Locale{
+	{Code: "da", Name: "Dansk", HrefLang: "da", Dir: "ltr"},
+	{Code: "en", Name: "English", HrefLang: "en", Dir: "ltr"},
+	{Code: "fa", Name: "دری", HrefLang: "fa", Dir: "rtl"},
+}
+
+var catalogues = struct {
+	mu   sync.RWMutex
+	msgs map[string]map[string]string
+}{msgs: map[string]map[string]string{}}
+
+// Load reads the translation catalogue <code>.json for every locale from dir
+func Load(dir string) error {
+	msgs := make(map[string]map[string]string, len(Locales))
+	for _, l := range Locales {
+		data, err := ioutil.ReadFile(filepath.Join(dir, l.Code+".json"))
+		if err != nil {
+			return err
+		}
+		catalogue := map[string]string{}
+		if err := json.Unmarshal(data, &catalogue); err != nil {
+			return fmt.Errorf("%s.json: %w", l.Code, err)
+		}
+		msgs[l.Code] = catalogue
+	}
+
+	catalogues.mu.Lock()
+	catalogues.msgs = msgs
+	catalogues.mu.Unlock()
+	return nil
+}
+
+// Get returns the locale with the given code
+func Get(code string) (Locale, bool) {
+	for _, l := range Locales {
+		if l.Code == code {
+			return l, true
+		}
+	}
+	return Locale{}, false
+}
+
+// T translates key into the given locale, falling back to the default
+// locale and finally the key itself. Extra args are applied with fmt.Sprintf.
+func T(locale, key string, args ...interface{}) string {
+	catalogues.mu.RLock()
+	msg, ok := catalogues.msgs[locale][key]
+	if !ok || msg == "" {
+		msg, ok = catalogues.msgs[Default][key]
+	}
+	catalogues.mu.RUnlock()
+
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Prefix returns the URL prefix for a locale, which is empty for the default
+func Prefix(locale string) string {
+	if locale == Default || locale == "" {
+		return ""
+	}
+	return "/" + locale
+}
+
+// SplitPath separates a locale prefix from a request path, returning the
+// locale and the path as it would be served in the default locale. The
+// locale is empty if the path has no prefix.
+func SplitPath(path string) (string, string) {
+	for _, l := range Locales {
+		if l.Code == Default {
+			continue
+		}
+		prefix := "/" + l.Code
+		if path == prefix || path == prefix+"/" {
+			return l.Code, "/"
+		}
+		if strings.HasPrefix(path, prefix+"/") {
+			return l.Code, strings.TrimPrefix(path, prefix)
+		}
+	}
+	return "", path
+}
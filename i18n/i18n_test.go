@@ -0,0 +1,60 @@
+package i18n
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if err := Load("../locales"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		key    string
+		args   []interface{}
+		want   string
+	}{
+		{"danish", "da", "nav.home", nil, "Forside"},
+		{"english", "en", "nav.home", nil, "Home"},
+		{"persian", "fa", "nav.home", nil, "صفحه اصلی"},
+		{"missing in locale falls back to danish", "en", "contact.default_phone", nil, T("da", "contact.default_phone")},
+		{"unknown locale falls back to danish", "de", "nav.home", nil, "Forside"},
+		{"unknown key is returned as is", "en", "no.such.key", nil, "no.such.key"},
+		{"arguments", "en", "time.ago", []interface{}{"3 days"}, "3 days ago"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(tt.locale, tt.key, tt.args...); got != tt.want {
+				t.Errorf("T(%q, %q) = %q, want %q", tt.locale, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path       string
+		wantLocale string
+		wantPath   string
+	}{
+		{"/", "", "/"},
+		{"/om-soma", "", "/om-soma"},
+		{"/en", "en", "/"},
+		{"/en/", "en", "/"},
+		{"/en/om-soma", "en", "/om-soma"},
+		{"/fa/blog/x", "fa", "/blog/x"},
+		{"/english", "", "/english"},
+	}
+	for _, tt := range tests {
+		locale, path := SplitPath(tt.path)
+		if locale != tt.wantLocale || path != tt.wantPath {
+			t.Errorf("SplitPath(%q) = %q, %q, want %q, %q", tt.path, locale, path, tt.wantLocale, tt.wantPath)
+		}
+	}
+}
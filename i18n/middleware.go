@@ -0,0 +1,142 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	localsKey = "locale"
+
+	// CookieName stores the visitor's chosen locale
+	CookieName = "lang"
+)
+
+// Alternate is a translated version of the current page
+type Alternate struct {
+	Locale  Locale
+	URL     string
+	Current bool
+}
+
+// New returns middleware that selects the locale for a request and makes it
+// available to handlers and templates. A URL prefix always wins; unprefixed
+// URLs use the "lang" cookie and then the Accept-Language header. Passing
+// ?lang=<code> stores the choice in the cookie.
+func New() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		code, path := SplitPath(c.Path())
+
+		if chosen := c.Query("lang"); chosen != "" {
+			if _, ok := Get(chosen); ok {
+				c.Cookie(&fiber.Cookie{
+					Name:     CookieName,
+					Value:    chosen,
+					Path:     "/",
+					Expires:  time.Now().AddDate(1, 0, 0),
+					SameSite: fiber.CookieSameSiteLaxMode,
+				})
+				if code == "" {
+					code = chosen
+				}
+			}
+		}
+
+		if code == "" {
+			code = negotiate(c.Cookies(CookieName), c.Get(fiber.HeaderAcceptLanguage))
+			c.Vary(fiber.HeaderAcceptLanguage, fiber.HeaderCookie)
+		}
+
+		locale, _ := Get(code)
+		c.Locals(localsKey, code)
+
+		base := c.BaseURL()
+		alternates := make([]Alternate, 0, len(Locales))
+		for _, l := range Locales {
+			alternates = append(alternates, Alternate{
+				Locale:  l,
+				URL:     base + localizePath(l.Code, path),
+				Current: l.Code == code,
+			})
+		}
+
+		if err := c.Bind(fiber.Map{
+			"Locale":       code,
+			"Dir":          locale.Dir,
+			"HrefLang":     locale.HrefLang,
+			"LocalePrefix": Prefix(code),
+			"Alternates":   alternates,
+			"DefaultURL":   base + path,
+		}); err != nil {
+			return err
+		}
+
+		return c.Next()
+	}
+}
+
+// FromCtx returns the locale selected for the request
+func FromCtx(c *fiber.Ctx) string {
+	if code, ok := c.Locals(localsKey).(string); ok && code != "" {
+		return code
+	}
+	return Default
+}
+
+func localizePath(locale, path string) string {
+	prefix := Prefix(locale)
+	if prefix != "" && path == "/" {
+		return prefix
+	}
+	return prefix + path
+}
+
+// negotiate picks a locale from the cookie value, then the Accept-Language
+// header, falling back to the default locale
+func negotiate(cookie, acceptLanguage string) string {
+	if _, ok := Get(cookie); ok {
+		return cookie
+	}
+
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, cand := range candidates {
+		primary := strings.SplitN(cand.tag, "-", 2)[0]
+		// Dari has its own ISO 639-3 code but is served as "fa"
+		if primary == "prs" {
+			primary = "fa"
+		}
+		if _, ok := Get(primary); ok {
+			return primary
+		}
+	}
+	return Default
+}
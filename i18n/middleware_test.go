@@ -0,0 +1,32 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		cookie         string
+		acceptLanguage string
+		want           string
+	}{
+		{"nothing set", "", "", "da"},
+		{"cookie wins", "fa", "en-GB,en;q=0.9", "fa"},
+		{"unknown cookie is ignored", "de", "en", "en"},
+		{"region is dropped", "", "en-GB", "en"},
+		{"case is ignored", "", "EN-us", "en"},
+		{"highest quality first", "", "en;q=0.5,fa;q=0.8", "fa"},
+		{"order breaks ties", "", "fa,en", "fa"},
+		{"unsupported languages are skipped", "", "de-DE,de;q=0.9,en;q=0.7", "en"},
+		{"dari is served as fa", "", "prs-AF", "fa"},
+		{"q=0 means not acceptable", "", "en;q=0,de", "da"},
+		{"nothing supported", "", "de,fr;q=0.9", "da"},
+		{"malformed quality counts as 1", "", "en;q=x", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiate(tt.cookie, tt.acceptLanguage); got != tt.want {
+				t.Errorf("negotiate(%q, %q) = %q, want %q", tt.cookie, tt.acceptLanguage, got, tt.want)
+			}
+		})
+	}
+}
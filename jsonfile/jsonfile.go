@@ -0,0 +1,41 @@
+// Package jsonfile reads and writes the JSON files the site keeps its state
+// in.
+package jsonfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Read decodes the JSON file at path into v
+func Read(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Write encodes v as indented JSON to path with the given permissions,
+// creating the directory if needed. It writes to a temporary file first so
+// a crash never leaves a torn file behind. Files only the server may read,
+// such as those holding personal data, get a directory only it can list.
+func Write(path string, v interface{}, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	dirPerm := os.FileMode(0755)
+	if perm&0077 == 0 {
+		dirPerm = 0700
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
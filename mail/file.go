@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileDrop writes each message as an .eml file to Dir instead of sending
+// it, for development and tests
+type FileDrop struct {
+	Dir  string
+	From string
+}
+
+// Send writes msg to a new file named after the time and recipient
+func (f FileDrop) Send(msg Message) error {
+	to, err := recipient(msg)
+	if err != nil {
+		return err
+	}
+	data, err := build(f.From, msg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.Dir, 0700); err != nil {
+		return err
+	}
+	name := time.Now().Format("20060102-150405.000000") + "-" + strings.NewReplacer("@", "_at_", "/", "_").Replace(to) + ".eml"
+	return ioutil.WriteFile(filepath.Join(f.Dir, name), data, 0600)
+}
+
+// Log writes messages to the log instead of sending them, so links in
+// them can be followed during development
+type Log struct{}
+
+// Send logs the recipient, subject, extra headers and text of msg
+func (Log) Send(msg Message) error {
+	to, err := recipient(msg)
+	if err != nil {
+		return err
+	}
+	attrs := []any{"to", to, "subject", msg.Subject}
+	for _, k := range sortedKeys(msg.Headers) {
+		attrs = append(attrs, "header."+k, msg.Headers[k])
+	}
+	attrs = append(attrs, "text", msg.Text)
+	slog.Info("mail: not sending, set SMTP_HOST or MAIL_TRANSPORT", attrs...)
+	return nil
+}
@@ -0,0 +1,170 @@
+// Package mail sends e-mail through a pluggable transport: an SMTP server, a
+// directory of .eml files for development and tests, or the log. Messages
+// can be sent directly or through a persistent Queue that retries them, and
+// rendered from per-language Templates.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoRecipient is returned for a message without a valid To address
+var ErrNoRecipient = errors.New("missing_recipient")
+
+// Message is an e-mail with a plain-text body and an optional HTML
+// alternative
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+
+	// Headers are extra headers such as List-Unsubscribe
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Transport delivers a message
+type Transport interface {
+	Send(msg Message) error
+}
+
+// DefaultTransport is used by Send, and by queues created without a
+// transport. Mail goes to the log while it is nil.
+var DefaultTransport Transport
+
+// Send delivers msg right away with the default transport
+func Send(msg Message) error {
+	t := DefaultTransport
+	if t == nil {
+		t = Log{}
+	}
+	return t.Send(msg)
+}
+
+// NewTransport picks a transport by name: "smtp", "file" (writing .eml files
+// to dropDir) or "log". Without a name, mail goes through smtp when it has a
+// host and to the log otherwise.
+func NewTransport(name, dropDir string, smtp SMTP) Transport {
+	switch name {
+	case "smtp":
+		return smtp
+	case "file":
+		return FileDrop{Dir: dropDir, From: smtp.From}
+	case "log":
+		return Log{}
+	}
+	if smtp.Host != "" {
+		return smtp
+	}
+	return Log{}
+}
+
+// recipient returns the bare address msg is sent to
+func recipient(msg Message) (string, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return "", ErrNoRecipient
+	}
+	return to.Address, nil
+}
+
+// build renders msg as an RFC 5322 message with quoted-printable bodies,
+// as multipart/alternative when it has an HTML version
+func build(from string, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	header("MIME-Version", "1.0")
+	for _, k := range sortedKeys(msg.Headers) {
+		header(k, msg.Headers[k])
+	}
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable encodes body with CRLF line endings
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// messageID returns a unique Message-ID in the sender's domain
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	return fmt.Sprintf("<%s.%d@%s>", randomHex(12), time.Now().Unix(), domain)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
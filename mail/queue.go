@@ -0,0 +1,311 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/jsonfile"
+)
+
+const (
+	// maxFailures is how many failed messages are kept for review
+	maxFailures = 500
+
+	queueInterval = 30 * time.Second
+)
+
+// Job is a queued message
+type Job struct {
+	ID          string    `json:"id"`
+	Message     Message   `json:"message"`
+	Attempts    int       `json:"attempts"`
+	QueuedAt    time.Time `json:"queued_at"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Failure records a message that was given up on. Permanent failures, such
+// as a mailbox that does not exist, are not retried, so a bouncing address
+// is never hammered.
+type Failure struct {
+	ID        string    `json:"id"`
+	To        string    `json:"to"`
+	Subject   string    `json:"subject"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	Permanent bool      `json:"permanent"`
+	QueuedAt  time.Time `json:"queued_at"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// Queue sends messages in the background and keeps them on disk until they
+// are delivered, retrying temporary failures with exponential backoff
+type Queue struct {
+	// MaxAttempts is how often a message is tried before it fails
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure; it doubles with
+	// every further attempt
+	BaseDelay time.Duration
+
+	transport    Transport
+	queueFile    string
+	failuresFile string
+
+	mu       sync.Mutex
+	loaded   bool
+	jobs     []Job
+	failures []Failure
+	kick     chan struct{}
+}
+
+// NewQueue returns a queue that sends through transport, or through
+// DefaultTransport if it is nil, and keeps its state in mail_queue.json and
+// mail_failures.json in dir
+func NewQueue(dir string, transport Transport) *Queue {
+	return &Queue{
+		MaxAttempts:  8,
+		BaseDelay:    time.Minute,
+		transport:    transport,
+		queueFile:    filepath.Join(dir, "mail_queue.json"),
+		failuresFile: filepath.Join(dir, "mail_failures.json"),
+	}
+}
+
+// Run works through the queue, including messages left over from before a
+// restart, until ctx is cancelled. It returns once the message being sent
+// has been delivered or put back in the queue.
+func (q *Queue) Run(ctx context.Context) {
+	q.mu.Lock()
+	q.loadLocked()
+	q.kick = make(chan struct{}, 1)
+	kick := q.kick
+	q.mu.Unlock()
+
+	ticker := time.NewTicker(queueInterval)
+	defer ticker.Stop()
+	for {
+		q.process(ctx)
+		select {
+		case <-ticker.C:
+		case <-kick:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Enqueue saves msg and wakes the sender
+func (q *Queue) Enqueue(msg Message) error {
+	if _, err := recipient(msg); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	q.mu.Lock()
+	q.loadLocked()
+	q.jobs = append(q.jobs, Job{ID: randomHex(8), Message: msg, QueuedAt: now, NextAttempt: now})
+	err := q.saveLocked()
+	kick := q.kick
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if kick != nil {
+		select {
+		case kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Jobs returns the messages waiting to be sent
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+	return append([]Job{}, q.jobs...)
+}
+
+// Failures returns the messages that could not be sent, newest first
+func (q *Queue) Failures() []Failure {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+	failures := make([]Failure, len(q.failures))
+	for i, f := range q.failures {
+		failures[len(q.failures)-1-i] = f
+	}
+	return failures
+}
+
+// Forget removes the queued messages to or replying to address and the
+// failed messages to it, for erasure requests, and returns how many were
+// removed
+func (q *Queue) Forget(address string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	removed := 0
+	jobs := q.jobs[:0]
+	for _, job := range q.jobs {
+		if job.Message.Involves(address) {
+			removed++
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	q.jobs = jobs
+	failures := q.failures[:0]
+	for _, f := range q.failures {
+		if sentTo(f.To, address) {
+			removed++
+			continue
+		}
+		failures = append(failures, f)
+	}
+	q.failures = failures
+
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := q.saveLocked(); err != nil {
+		return removed, err
+	}
+	return removed, jsonfile.Write(q.failuresFile, q.failures, 0600)
+}
+
+// RemoveFailure drops a failed message from the record
+func (q *Queue) RemoveFailure(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loadLocked()
+
+	for i, f := range q.failures {
+		if f.ID == id {
+			q.failures = append(q.failures[:i], q.failures[i+1:]...)
+			return jsonfile.Write(q.failuresFile, q.failures, 0600)
+		}
+	}
+	return os.ErrNotExist
+}
+
+// process tries every job that is due, stopping early when ctx is cancelled
+func (q *Queue) process(ctx context.Context) {
+	now := time.Now()
+	q.mu.Lock()
+	var due []Job
+	for _, job := range q.jobs {
+		if !now.Before(job.NextAttempt) {
+			due = append(due, job)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, job := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		var err error
+		if q.transport != nil {
+			err = q.transport.Send(job.Message)
+		} else {
+			err = Send(job.Message)
+		}
+
+		q.mu.Lock()
+		jobs := q.jobs[:0]
+		for _, j := range q.jobs {
+			if j.ID != job.ID {
+				jobs = append(jobs, j)
+			}
+		}
+		q.jobs = jobs
+
+		if err != nil {
+			job.Attempts++
+			job.LastError = err.Error()
+			permanent := IsPermanent(err)
+			if permanent || job.Attempts >= q.MaxAttempts {
+				q.failLocked(job, permanent)
+				slog.Error("mail: giving up", "job_id", job.ID, "subject", job.Message.Subject, "attempts", job.Attempts, "err", err)
+			} else {
+				backoff := q.BaseDelay << uint(job.Attempts-1)
+				job.NextAttempt = time.Now().Add(backoff)
+				q.jobs = append(q.jobs, job)
+				slog.Warn("mail: sending failed, will retry", "job_id", job.ID, "subject", job.Message.Subject, "retry_in", backoff.String(), "err", err)
+			}
+		}
+		if err := q.saveLocked(); err != nil {
+			slog.Error("mail: could not save queue", "err", err)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// failLocked records a job that is given up on, keeping the newest
+// maxFailures. The message body is not kept.
+func (q *Queue) failLocked(job Job, permanent bool) {
+	q.failures = append(q.failures, Failure{
+		ID:        job.ID,
+		To:        job.Message.To,
+		Subject:   job.Message.Subject,
+		Error:     job.LastError,
+		Attempts:  job.Attempts,
+		Permanent: permanent,
+		QueuedAt:  job.QueuedAt,
+		FailedAt:  time.Now(),
+	})
+	if len(q.failures) > maxFailures {
+		q.failures = q.failures[len(q.failures)-maxFailures:]
+	}
+	if err := jsonfile.Write(q.failuresFile, q.failures, 0600); err != nil {
+		slog.Error("mail: could not save failures", "err", err)
+	}
+}
+
+// sentTo reports whether the To header to names address
+func sentTo(to, address string) bool {
+	addr, err := recipient(Message{To: to})
+	return err == nil && strings.EqualFold(addr, address)
+}
+
+// Involves reports whether msg is sent to address or asks for replies to it
+func (msg Message) Involves(address string) bool {
+	return sentTo(msg.To, address) || sentTo(msg.Headers["Reply-To"], address)
+}
+
+func (q *Queue) loadLocked() {
+	if q.loaded {
+		return
+	}
+	q.loaded = true
+	for path, v := range map[string]interface{}{q.queueFile: &q.jobs, q.failuresFile: &q.failures} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				slog.Warn("mail: ignoring unreadable file", "file", path, "err", err)
+			}
+			continue
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			slog.Warn("mail: ignoring unreadable file", "file", path, "err", err)
+		}
+	}
+}
+
+func (q *Queue) saveLocked() error {
+	if q.jobs == nil {
+		q.jobs = []Job{}
+	}
+	return jsonfile.Write(q.queueFile, q.jobs, 0600)
+}
@@ -0,0 +1,166 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTransport records the messages it is given and fails those to
+// addresses listed in fail
+type fakeTransport struct {
+	mu   sync.Mutex
+	sent []Message
+	fail map[string]error
+}
+
+func (f *fakeTransport) Send(msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.fail[msg.To]; err != nil {
+		return err
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	unknownMailbox := &textproto.Error{Code: 550, Msg: "5.1.1 No such user"}
+	tests := []struct {
+		name          string
+		err           error
+		attempts      int // made before this one
+		wantSent      bool
+		wantQueued    bool
+		wantRetry     time.Duration
+		wantFailed    bool
+		wantPermanent bool
+	}{
+		{name: "delivered", wantSent: true},
+		{name: "temporary failure", err: errors.New("connection refused"), wantQueued: true, wantRetry: time.Minute},
+		{name: "backs off", err: &textproto.Error{Code: 451, Msg: "Try later"}, attempts: 3, wantQueued: true, wantRetry: 8 * time.Minute},
+		{name: "gives up", err: errors.New("connection refused"), attempts: 7, wantFailed: true},
+		{name: "rejected", err: unknownMailbox, wantFailed: true, wantPermanent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			transport := &fakeTransport{fail: map[string]error{"soma@example.org": tt.err}}
+			q := NewQueue(dir, transport)
+			if err := q.Enqueue(Message{To: "soma@example.org", Subject: "Bekræft", Text: "Hej"}); err != nil {
+				t.Fatal(err)
+			}
+			q.mu.Lock()
+			q.jobs[0].Attempts = tt.attempts
+			q.mu.Unlock()
+
+			q.process(context.Background())
+
+			if sent := len(transport.sent) == 1; sent != tt.wantSent {
+				t.Errorf("sent %v, want %v", sent, tt.wantSent)
+			}
+			// A new queue over the same directory sees the same state
+			reloaded := NewQueue(dir, transport)
+			jobs := reloaded.Jobs()
+			if queued := len(jobs) == 1; queued != tt.wantQueued {
+				t.Fatalf("queued %v, want %v", queued, tt.wantQueued)
+			}
+			if tt.wantQueued {
+				wait := time.Until(jobs[0].NextAttempt)
+				if jobs[0].Attempts != tt.attempts+1 || wait > tt.wantRetry || wait < tt.wantRetry-time.Second {
+					t.Errorf("attempt %d, next in %v, want attempt %d in %v", jobs[0].Attempts, wait, tt.attempts+1, tt.wantRetry)
+				}
+			}
+			failures := reloaded.Failures()
+			if failed := len(failures) == 1; failed != tt.wantFailed {
+				t.Fatalf("failed %v, want %v", failed, tt.wantFailed)
+			}
+			if tt.wantFailed && (failures[0].Permanent != tt.wantPermanent || failures[0].To != "soma@example.org" || failures[0].Subject != "Bekræft") {
+				t.Errorf("failure %+v, want permanent %v", failures[0], tt.wantPermanent)
+			}
+		})
+	}
+}
+
+func TestQueueEnqueueNeedsRecipient(t *testing.T) {
+	q := NewQueue(t.TempDir(), &fakeTransport{})
+	if err := q.Enqueue(Message{To: "not an address", Subject: "Hej"}); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("error = %v, want %v", err, ErrNoRecipient)
+	}
+	if jobs := q.Jobs(); len(jobs) != 0 {
+		t.Errorf("queued %+v", jobs)
+	}
+}
+
+func TestQueueForget(t *testing.T) {
+	transport := &fakeTransport{fail: map[string]error{"Bounce <bounce@example.org>": &textproto.Error{Code: 550, Msg: "No such user"}}}
+	q := NewQueue(t.TempDir(), transport)
+	q.Enqueue(Message{To: "Bounce <bounce@example.org>", Subject: "1"})
+	q.process(context.Background())
+
+	transport.fail["soma@example.org"] = errors.New("connection refused")
+	for _, msg := range []Message{
+		{To: "soma@example.org", Subject: "2"},
+		{To: "kontakt@example.org", Subject: "3", Headers: map[string]string{"Reply-To": "Bounce <BOUNCE@example.org>"}},
+	} {
+		if err := q.Enqueue(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := q.Forget("bounce@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("forgot %d messages, want the failure and the reply", n)
+	}
+	if jobs := q.Jobs(); len(jobs) != 1 || jobs[0].Message.Subject != "2" {
+		t.Errorf("jobs %+v, want only the message to soma@example.org", jobs)
+	}
+	if failures := q.Failures(); len(failures) != 0 {
+		t.Errorf("failures %+v, want none", failures)
+	}
+}
+
+func TestQueueRun(t *testing.T) {
+	transport := &fakeTransport{}
+	q := NewQueue(t.TempDir(), transport)
+	// Queued before the sender starts
+	q.Enqueue(Message{To: "soma@example.org", Subject: "1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitForEmptyQueue := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for len(q.Jobs()) > 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("still queued: %+v", q.Jobs())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForEmptyQueue()
+	// Wakes the running sender rather than waiting for its ticker
+	q.Enqueue(Message{To: "soma@example.org", Subject: "2"})
+	waitForEmptyQueue()
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.sent) != 2 {
+		t.Errorf("sent %d messages, want 2", len(transport.sent))
+	}
+}
@@ -0,0 +1,148 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// SMTP delivers mail through an SMTP server
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// smtpTimeout bounds connecting to the server and, separately, the whole
+// conversation that delivers one message, so a stalled server cannot hold
+// up the mail queue
+const smtpTimeout = time.Minute
+
+// Send delivers msg through the SMTP server. Port 465 uses implicit TLS;
+// other ports upgrade with STARTTLS when the server offers it.
+func (s SMTP) Send(msg Message) error {
+	to, err := recipient(msg)
+	if err != nil {
+		return err
+	}
+	from := s.From
+	if addr, err := mail.ParseAddress(from); err == nil {
+		from = addr.Address
+	}
+	data, err := build(s.From, msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if s.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support AUTH")
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial connects to the server and says hello, upgrading the connection
+// with STARTTLS when it is not already encrypted and the server offers it
+func (s SMTP) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(s.Host, s.Port)
+	conn, err := (&net.Dialer{Timeout: smtpTimeout}).Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	tlsConfig := &tls.Config{ServerName: s.Host}
+	if s.Port == "465" {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := client.Hello("localhost"); err != nil {
+		client.Close()
+		return nil, err
+	}
+	if _, isTLS := conn.(*tls.Conn); !isTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, err
+			}
+		}
+	}
+	return client, nil
+}
+
+// Ping connects to the server and waits for its greeting, without logging
+// in or sending anything
+func (s SMTP) Ping(ctx context.Context) error {
+	if s.Host == "" {
+		return errors.New("SMTP_HOST is not set")
+	}
+	addr := net.JoinHostPort(s.Host, s.Port)
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if s.Port == "465" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return err
+	}
+	text.PrintfLine("QUIT")
+	return nil
+}
+
+// IsPermanent reports whether retrying cannot help: the address is
+// malformed or the server rejected the message with a 5xx reply, e.g.
+// because the mailbox does not exist
+func IsPermanent(err error) bool {
+	if errors.Is(err, ErrNoRecipient) {
+		return true
+	}
+	var reply *textproto.Error
+	return errors.As(err, &reply) && reply.Code >= 500
+}
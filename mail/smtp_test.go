@@ -0,0 +1,191 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a plain-text SMTP server that accepts mail for any address
+// except those in reject, which get a 550 reply
+type fakeSMTP struct {
+	ln     net.Listener
+	reject map[string]bool
+
+	mu       sync.Mutex
+	messages []string
+	rcpts    []string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, reject: map[string]bool{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// transport returns an SMTP transport for the server
+func (s *fakeSMTP) transport() SMTP {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return SMTP{Host: host, Port: port, From: "Soma Mayel <nyhedsbrev@example.org>"}
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"):
+			reply("250 fake")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			addr := strings.Trim(cmd[len("RCPT TO:"):], "<> ")
+			if s.reject[addr] {
+				reply("550 5.1.1 No such user")
+				continue
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, addr)
+			s.mu.Unlock()
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 Queued")
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	srv := newFakeSMTP(t)
+	err := srv.transport().Send(Message{
+		To:      "Ny Vælger <vaelger@example.org>",
+		Subject: "Bekræft din tilmelding",
+		Text:    "Hej\nKlik her",
+		HTML:    "<p>Hej</p>",
+		Headers: map[string]string{"List-Unsubscribe": "<https://example.org/afmeld>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.rcpts) != 1 || srv.rcpts[0] != "vaelger@example.org" {
+		t.Errorf("recipients %v, want the bare address", srv.rcpts)
+	}
+	if len(srv.messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(srv.messages))
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(srv.messages[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Bekræft din tilmelding" {
+		t.Errorf("subject %q", subject)
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "<https://example.org/afmeld>" {
+		t.Errorf("List-Unsubscribe %q", got)
+	}
+	if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/alternative") {
+		t.Errorf("content type %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.org>") {
+		t.Errorf("message ID %q, want one in the sender's domain", msg.Header.Get("Message-ID"))
+	}
+}
+
+func TestSMTPSendErrors(t *testing.T) {
+	srv := newFakeSMTP(t)
+	srv.reject["nobody@example.org"] = true
+
+	err := srv.transport().Send(Message{To: "nobody@example.org", Subject: "Hej", Text: "Hej"})
+	if err == nil || !IsPermanent(err) {
+		t.Errorf("unknown mailbox: error = %v, want a permanent one", err)
+	}
+
+	if err := srv.transport().Send(Message{To: "", Subject: "Hej"}); !IsPermanent(err) {
+		t.Errorf("no recipient: error = %v, want a permanent one", err)
+	}
+
+	withLogin := srv.transport()
+	withLogin.Username = "soma"
+	withLogin.Password = "secret"
+	if err := withLogin.Send(Message{To: "soma@example.org", Subject: "Hej"}); err == nil || !strings.Contains(err.Error(), "AUTH") {
+		t.Errorf("login without AUTH: error = %v", err)
+	}
+
+	down := srv.transport()
+	srv.ln.Close()
+	err = down.Send(Message{To: "soma@example.org", Subject: "Hej"})
+	if err == nil || IsPermanent(err) {
+		t.Errorf("server down: error = %v, want a temporary one", err)
+	}
+}
+
+func TestSMTPPing(t *testing.T) {
+	srv := newFakeSMTP(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.transport().Ping(ctx); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+	if err := (SMTP{}).Ping(ctx); err == nil {
+		t.Error("ping without a host succeeded")
+	}
+	down := srv.transport()
+	srv.ln.Close()
+	if err := down.Ping(ctx); err == nil {
+		t.Error("ping of a closed server succeeded")
+	}
+}
@@ -0,0 +1,61 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Templates renders messages from files in Dir of FS. A message named
+// "welcome" in Danish is welcome.da.txt, a text template whose "subject"
+// block is the subject and whose body is the plain-text version, plus an
+// optional welcome.da.html for the HTML version. Missing languages fall back
+// to Fallback.
+type Templates struct {
+	FS       fs.FS
+	Dir      string
+	Fallback string
+	Funcs    map[string]interface{}
+}
+
+// Render builds the message called name in locale for data. The recipient
+// is left for the caller to fill in.
+func (t Templates) Render(name, locale string, data interface{}) (Message, error) {
+	base := path.Join(t.Dir, name+"."+locale)
+	if _, err := fs.Stat(t.FS, base+".txt"); errors.Is(err, fs.ErrNotExist) && t.Fallback != "" {
+		base = path.Join(t.Dir, name+"."+t.Fallback)
+	}
+
+	text, err := texttemplate.New(path.Base(base+".txt")).Funcs(t.Funcs).ParseFS(t.FS, base+".txt")
+	if err != nil {
+		return Message{}, err
+	}
+	var subject, body bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+	msg := Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(body.String()) + "\n",
+	}
+
+	if _, err := fs.Stat(t.FS, base+".html"); err == nil {
+		html, err := htmltemplate.New(path.Base(base+".html")).Funcs(t.Funcs).ParseFS(t.FS, base+".html")
+		if err != nil {
+			return Message{}, err
+		}
+		var out bytes.Buffer
+		if err := html.Execute(&out, data); err != nil {
+			return Message{}, err
+		}
+		msg.HTML = out.String()
+	}
+	return msg, nil
+}
@@ -1,75 +1,220 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"soma-mayel-campaign/assets"
+	"soma-mayel-campaign/config"
 	"soma-mayel-campaign/handlers"
+	"soma-mayel-campaign/i18n"
+	"soma-mayel-campaign/logging"
+	mailer "soma-mayel-campaign/mail"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/basicauth"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/template/html/v2"
-	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	// Load environment variables
-	godotenv.Load()
+	// Load and check the configuration from .env and the environment
+	cfg, err := config.Load()
+	if err != nil {
+		// The problems are joined; log them one by one
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		for _, e := range errs {
+			slog.Error("Invalid configuration", "err", e)
+		}
+		os.Exit(1)
+	}
+
+	// Structured logging, as text or JSON (LOG_FORMAT) from LOG_LEVEL up
+	logger, err := logging.New(os.Stderr, cfg.Logging.Format, cfg.Logging.Level)
+	if err != nil {
+		slog.Error("Invalid logging configuration", "err", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+	slog.Info("Configuration", cfg.Summary()...)
+	for _, w := range cfg.Warnings() {
+		slog.Warn("Configuration: " + w)
+	}
+
+	// Load translation catalogues
+	if err := i18n.Load("./locales"); err != nil {
+		slog.Error("Failed to load translations", "err", err)
+		os.Exit(1)
+	}
+
+	// Templates and assets come from disk in development and from the
+	// binary in production
+	templateFiles, staticFiles := siteFiles(cfg)
+
+	// Handlers and background workers share the configuration through site
+	site := handlers.New(cfg, templateFiles)
+	mailer.DefaultTransport = site.MailTransport()
 
-	// Create template engine
-	engine := html.New("./templates", ".html")
-	engine.Reload(true)
-	engine.Debug(true)
+	// Production fingerprints the CSS and JavaScript so they can be cached
+	// for good; development links to the plain files
+	manifest := assets.Plain("/static")
+	if cfg.IsProduction() {
+		if manifest, err = assets.Build(staticFiles, "/static", "css", "js"); err != nil {
+			slog.Error("Failed to fingerprint assets", "err", err)
+			os.Exit(1)
+		}
+		slog.Info("Assets fingerprinted", "files", manifest.Len())
+	}
+
+	// Create template engine. Development re-parses the templates on every
+	// request; production parses them once, here, and refuses to start if
+	// one of them is broken.
+	engine := html.NewFileSystem(http.FS(templateFiles), ".html")
+	if !cfg.IsProduction() {
+		engine.Reload(true)
+		engine.Debug(true)
+	}
+	engine.AddFunc("t", i18n.T)
+	engine.AddFunc("date", i18n.FormatDate)
+	engine.AddFunc("number", i18n.FormatNumber)
+	engine.AddFunc("timeago", i18n.TimeAgo)
+	engine.AddFunc("formtoken", site.FormToken)
+	engine.AddFunc("asset", manifest.URL)
+	if err := engine.Load(); err != nil {
+		slog.Error("Failed to parse templates", "err", err)
+		os.Exit(1)
+	}
+	site.TemplatesLoaded()
 
 	// Create fiber app with template engine
-	app := fiber.New(fiber.Config{
-		Views:       engine,
-		ViewsLayout: "layouts/main",
-	})
+	fiberConfig := fiber.Config{
+		Views:        handlers.CountRenderErrors(engine),
+		ViewsLayout:  "layouts/main",
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		BodyLimit:    cfg.Server.BodyLimitMB << 20,
+	}
+	// Behind a reverse proxy the client address comes from a header, which
+	// is only believed from TRUSTED_PROXIES when that is set
+	if cfg.Server.ProxyHeader != "" {
+		fiberConfig.ProxyHeader = cfg.Server.ProxyHeader
+		if len(cfg.Server.TrustedProxies) > 0 {
+			fiberConfig.EnableTrustedProxyCheck = true
+			fiberConfig.TrustedProxies = cfg.Server.TrustedProxies
+		}
+	}
+	app := fiber.New(fiberConfig)
+
+	// Health checks, ahead of the middleware so probes are not logged
+	app.Get("/healthz", handlers.Healthz)
+	app.Get("/readyz", site.Readyz)
 
 	// Middleware
-	app.Use(logger.New())
-	app.Use(recover.New())
+	app.Use(requestid.New())
+	app.Use(handlers.RequestLogger())
+	app.Use(handlers.Metrics())
+	app.Use(recover.New(recover.Config{
+		EnableStackTrace:  true,
+		StackTraceHandler: handlers.LogPanic,
+	}))
 	app.Use(compress.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowHeaders: "Origin, Content-Type, Accept",
 	}))
 
-	// Static files
+	// Static files. In production the CSS and JavaScript are served from the
+	// binary, under their fingerprinted names with a long cache lifetime;
+	// anything else, such as uploads, falls through to ./static.
+	if cfg.IsProduction() {
+		app.Use("/static", manifest.Handler())
+		app.Use("/static", filesystem.New(filesystem.Config{Root: http.FS(staticFiles)}))
+	}
 	app.Static("/static", "./static")
 	app.Static("/content", "./content")
 
+	// Locale selection from the URL prefix
+	app.Use(i18n.New())
+
+	// Cookieless page view counts
+	app.Use(site.Analytics())
+
+	// Public pages, served in Danish at the root and under /en and /fa
+	pages := func(r fiber.Router) {
+		r.Get("/", handlers.Home)
+		r.Get("/om-soma", handlers.About)
+		r.Get("/politik", handlers.Politics)
+		r.Get("/politik/:slug", handlers.PolicyArea)
+		r.Get("/nyheder", handlers.News)
+		r.Get("/kontakt", handlers.Contact)
+		r.Get("/blog/:slug", handlers.BlogPost)
+		r.Get("/nyhedsbrev", handlers.Newsletter)
+		r.Get("/nyhedsbrev/bekraeft", site.NewsletterConfirm)
+		r.Get("/nyhedsbrev/afmeld", site.NewsletterUnsubscribePage)
+		r.Post("/nyhedsbrev/afmeld", site.NewsletterUnsubscribe)
+	}
+	pages(app)
+	for _, l := range i18n.Locales {
+		if l.Code != i18n.Default {
+			pages(app.Group(i18n.Prefix(l.Code)))
+		}
+	}
+
+	// Background workers run until SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	handlers.StartWorkers(ctx)
+	site.StartSocialRefresher(ctx)
+	site.StartFacebookPublisher(ctx)
+	site.StartNewsletterSender(ctx)
+	handlers.StartMailQueue(ctx)
+	site.StartRetention(ctx)
+	handlers.StartAnalytics(ctx)
+
 	// Routes
-	app.Get("/", handlers.Home)
-	app.Get("/om-soma", handlers.About)
-	app.Get("/politik", handlers.Politics)
-	app.Get("/nyheder", handlers.News)
-	app.Get("/kontakt", handlers.Contact)
-	app.Get("/blog/:slug", handlers.BlogPost)
-	app.Get("/api/facebook/feed", handlers.FacebookFeed)
-
-	// TinaCMS API routes
-	app.Post("/api/tina/content", handlers.TinaContentAPI)
-	app.Get("/api/tina/content/:collection", handlers.TinaGetContent)
+	app.Get("/api/facebook/feed", site.FacebookFeed)
+	app.Get("/api/social/feed", site.SocialFeed)
+	app.Post("/api/newsletter/subscribe", site.ProtectForm("newsletter"), site.NewsletterSubscribe)
 
 	// Admin authentication (Basic Auth)
-	adminUser := os.Getenv("ADMIN_USERNAME")
-	adminPass := os.Getenv("ADMIN_PASSWORD")
-	if adminUser == "" || adminPass == "" {
-		adminUser = "admin"
-		adminPass = "admin123"
-	}
 	adminAuth := basicauth.New(basicauth.Config{
 		Users: map[string]string{
-			adminUser: adminPass,
+			cfg.Admin.Username: cfg.Admin.Password,
 		},
 		Realm: "Restricted",
 	})
 
+	// Prometheus metrics, on their own address when METRICS_ADDR is set and
+	// behind the admin login otherwise
+	var metricsServer *http.Server
+	if addr := cfg.Server.MetricsAddr; addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsServer = &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			slog.Info("Metrics listening", "url", "http://"+addr+"/metrics")
+			if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				slog.Error("Metrics server stopped", "err", err)
+				os.Exit(1)
+			}
+		}()
+	} else {
+		app.Get("/metrics", adminAuth, handlers.MetricsHandler)
+	}
+
 	// Admin UI
 	adminUI := app.Group("/admin", adminAuth)
 	adminUI.Get("/", handlers.AdminPage)
@@ -80,14 +225,75 @@ func main() {
 	adminAPI.Get("/posts/:id", handlers.AdminGetPost)
 	adminAPI.Post("/posts", handlers.AdminUpsertPost)
 	adminAPI.Delete("/posts/:id", handlers.AdminDeletePost)
+	adminAPI.Post("/posts/:id/facebook", handlers.AdminPublishPostToFacebook)
 	adminAPI.Post("/upload", handlers.AdminUpload)
+	adminAPI.Post("/facebook/import", site.AdminImportFacebookPosts)
+	adminAPI.Get("/facebook/token", site.AdminFacebookTokenStatus)
+	adminAPI.Post("/facebook/token", site.AdminSaveFacebookToken)
+	adminAPI.Delete("/facebook/token", site.AdminDeleteFacebookToken)
+	adminAPI.Get("/facebook/token/debug", site.AdminDebugFacebookToken)
+	adminAPI.Get("/health", site.AdminHealth)
+	adminAPI.Get("/mail", handlers.AdminMailStatus)
+	adminAPI.Get("/quarantine", handlers.AdminListQuarantine)
+	adminAPI.Post("/quarantine/:id/release", site.AdminReleaseQuarantined)
+	adminAPI.Delete("/quarantine/:id", handlers.AdminDeleteQuarantined)
+	adminAPI.Post("/privacy/lookup", site.AdminPrivacyLookup)
+	adminAPI.Post("/privacy/export", site.AdminPrivacyExport)
+	adminAPI.Post("/privacy/erase", site.AdminPrivacyErase)
+	adminAPI.Get("/privacy/audit", handlers.AdminPrivacyAudit)
+	adminAPI.Get("/retention", site.AdminRetentionReport)
+	adminAPI.Get("/analytics", handlers.AdminAnalytics)
+	adminAPI.Get("/newsletter/subscribers", handlers.AdminListSubscribers)
+	adminAPI.Get("/newsletter/campaigns", handlers.AdminListCampaigns)
+	adminAPI.Get("/newsletter/campaigns/:id", handlers.AdminGetCampaign)
+	adminAPI.Post("/newsletter/campaigns", handlers.AdminUpsertCampaign)
+	adminAPI.Delete("/newsletter/campaigns/:id", handlers.AdminDeleteCampaign)
+	adminAPI.Get("/newsletter/campaigns/:id/preview", site.AdminPreviewCampaign)
+	adminAPI.Post("/newsletter/campaigns/:id/test", site.AdminTestCampaign)
+	adminAPI.Post("/newsletter/campaigns/:id/send", handlers.AdminSendCampaign)
+	adminAPI.Get("/policies", handlers.AdminListPolicyAreas)
+	adminAPI.Get("/policies/:id", handlers.AdminGetPolicyArea)
+	adminAPI.Post("/policies", handlers.AdminUpsertPolicyArea)
+	adminAPI.Delete("/policies/:id", handlers.AdminDeletePolicyArea)
+
+	// TinaCMS content API, which writes the pages and settings behind the
+	// public site
+	tinaAPI := app.Group("/api/tina", adminAuth)
+	tinaAPI.Post("/content", handlers.TinaContentAPI)
+	tinaAPI.Get("/content/:collection", handlers.TinaGetContent)
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3000"
-	}
+	port := cfg.Server.Port
+
+	// On SIGINT or SIGTERM stop accepting connections, let requests in
+	// flight and the background workers finish, then exit
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		// A second signal stops the server straight away
+		stop()
+		timeout := cfg.Server.ShutdownTimeout
+		slog.Info("Shutting down", "timeout", timeout.String())
+		deadline, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
 
-	log.Printf("Server starting on http://localhost:%s", port)
-	log.Fatal(app.Listen(":" + port))
-}
\ No newline at end of file
+		if err := app.ShutdownWithContext(deadline); err != nil {
+			slog.Error("Requests still in flight at shutdown", "err", err)
+		}
+		if metricsServer != nil {
+			metricsServer.Shutdown(deadline)
+		}
+		if err := handlers.WaitForWorkers(deadline); err != nil {
+			slog.Error("Background workers still running at shutdown", "err", err)
+		}
+	}()
+
+	slog.Info("Server starting", "url", "http://localhost:"+port)
+	if err := app.Listen(":" + port); err != nil {
+		slog.Error("Server stopped", "err", err)
+		os.Exit(1)
+	}
+	<-drained
+	slog.Info("Server stopped")
+}
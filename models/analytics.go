@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"soma-mayel-campaign/jsonfile"
+)
+
+// DailyStats are one day's page views, counted per page, traffic source,
+// device class and language. They are aggregates only: no cookies, IP
+// addresses or other visitor identifiers are recorded.
+type DailyStats struct {
+	Date      string         `json:"date"`
+	Views     int            `json:"views"`
+	Paths     map[string]int `json:"paths"`
+	Referrers map[string]int `json:"referrers"`
+	Devices   map[string]int `json:"devices"`
+	Locales   map[string]int `json:"locales"`
+}
+
+const analyticsDir = "./data/analytics"
+
+// NewDailyStats returns empty stats for date (YYYY-MM-DD)
+func NewDailyStats(date string) DailyStats {
+	return DailyStats{
+		Date:      date,
+		Paths:     map[string]int{},
+		Referrers: map[string]int{},
+		Devices:   map[string]int{},
+		Locales:   map[string]int{},
+	}
+}
+
+// GetDailyStats returns the stats of a day, empty if nothing was recorded
+func GetDailyStats(date string) DailyStats {
+	stats := NewDailyStats(date)
+	data, err := ioutil.ReadFile(filepath.Join(analyticsDir, date+".json"))
+	if err != nil {
+		return stats
+	}
+	json.Unmarshal(data, &stats)
+	for _, m := range []*map[string]int{&stats.Paths, &stats.Referrers, &stats.Devices, &stats.Locales} {
+		if *m == nil {
+			*m = map[string]int{}
+		}
+	}
+	return stats
+}
+
+// SaveDailyStats writes the stats of a day
+func SaveDailyStats(stats DailyStats) error {
+	return jsonfile.Write(filepath.Join(analyticsDir, stats.Date+".json"), stats, 0600)
+}
+
+// Add counts other's views into s
+func (s *DailyStats) Add(other DailyStats) {
+	s.Views += other.Views
+	add := func(dst, src map[string]int) {
+		for k, n := range src {
+			dst[k] += n
+		}
+	}
+	add(s.Paths, other.Paths)
+	add(s.Referrers, other.Referrers)
+	add(s.Devices, other.Devices)
+	add(s.Locales, other.Locales)
+}
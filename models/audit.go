@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"soma-mayel-campaign/jsonfile"
+)
+
+// AuditEntry records a data subject request that was carried out. The
+// person is identified by a keyed hash of their e-mail address rather than
+// the address itself, so the log does not undo an erasure.
+type AuditEntry struct {
+	ID      string         `json:"id"`
+	Action  string         `json:"action"`
+	Subject string         `json:"subject"`
+	Mode    string         `json:"mode,omitempty"`
+	Reason  string         `json:"reason,omitempty"`
+	Counts  map[string]int `json:"counts"`
+	Actor   string         `json:"actor"`
+	At      time.Time      `json:"at"`
+}
+
+const auditFile = "./data/privacy_audit.json"
+
+var auditMu sync.Mutex
+
+// GetAuditLog returns the audit log, newest first
+func GetAuditLog() ([]AuditEntry, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	entries, err := readAuditLog()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// AppendAudit adds an entry to the audit log, assigning its ID. It refuses
+// to if the log cannot be read, rather than replace it.
+func AppendAudit(e *AuditEntry) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	entries, err := readAuditLog()
+	if err != nil {
+		return err
+	}
+	id, err := randomID()
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	return jsonfile.Write(auditFile, append(entries, *e), 0600)
+}
+
+func readAuditLog() ([]AuditEntry, error) {
+	data, err := ioutil.ReadFile(auditFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []AuditEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", auditFile, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAppendAudit(t *testing.T) {
+	inTempDir(t)
+
+	for _, action := range []string{"export", "erase"} {
+		if err := AppendAudit(&AuditEntry{Action: action, Subject: "abc", At: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := GetAuditLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "erase" || entries[1].Action != "export" {
+		t.Fatalf("entries %+v, want erase then export", entries)
+	}
+	if entries[0].ID == "" || entries[0].ID == entries[1].ID {
+		t.Errorf("entry IDs %q and %q, want two different IDs", entries[0].ID, entries[1].ID)
+	}
+}
+
+func TestAppendAuditKeepsCorruptLog(t *testing.T) {
+	inTempDir(t)
+	const corrupt = `[{"id": "1", "action": "erase"`
+	writeTestFile(t, auditFile, corrupt)
+
+	if _, err := GetAuditLog(); err == nil {
+		t.Error("GetAuditLog read a corrupt log without an error")
+	}
+	if err := AppendAudit(&AuditEntry{Action: "export", Subject: "abc"}); err == nil {
+		t.Error("AppendAudit appended to a corrupt log")
+	}
+	data, err := os.ReadFile(auditFile)
+	if err != nil || string(data) != corrupt {
+		t.Errorf("log is now %q (%v), want it left as it was", data, err)
+	}
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"soma-mayel-campaign/jsonfile"
+)
+
+// Campaign statuses. Only drafts can be edited; a campaign that is sending
+// keeps going across restarts until every recipient has been tried.
+const (
+	CampaignDraft   = "draft"
+	CampaignSending = "sending"
+	CampaignSent    = "sent"
+)
+
+// Campaign recipient statuses
+const (
+	RecipientPending = "pending"
+	RecipientSent    = "sent"
+	RecipientFailed  = "failed"
+	// RecipientSkipped is a subscriber who unsubscribed after sending started
+	RecipientSkipped = "skipped"
+)
+
+// Campaign is a newsletter built from free text and a selection of posts
+type Campaign struct {
+	ID      string   `json:"id"`
+	Subject string   `json:"subject"`
+	Intro   string   `json:"intro"`
+	PostIDs []string `json:"post_ids"`
+	Status  string   `json:"status"`
+
+	// Recipients are the confirmed subscribers when sending started
+	Recipients []CampaignRecipient `json:"recipients,omitempty"`
+	// BaseURL is the site's address when sending started, used for links
+	// if SITE_URL is not set
+	BaseURL string `json:"base_url,omitempty"`
+
+	TestSentTo string     `json:"test_sent_to,omitempty"`
+	TestSentAt *time.Time `json:"test_sent_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// CampaignRecipient is the delivery state of a campaign for one subscriber
+type CampaignRecipient struct {
+	SubscriberID string     `json:"subscriber_id"`
+	Email        string     `json:"email"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	SentAt       *time.Time `json:"sent_at,omitempty"`
+}
+
+// RecipientCounts returns the number of recipients per status
+func (c Campaign) RecipientCounts() map[string]int {
+	counts := map[string]int{
+		RecipientPending: 0,
+		RecipientSent:    0,
+		RecipientFailed:  0,
+		RecipientSkipped: 0,
+	}
+	for _, r := range c.Recipients {
+		counts[r.Status]++
+	}
+	return counts
+}
+
+const campaignDir = "./data/campaigns"
+
+// GetAllCampaigns returns all campaigns, newest first
+func GetAllCampaigns() []Campaign {
+	files, err := ioutil.ReadDir(campaignDir)
+	if err != nil {
+		return nil
+	}
+
+	var campaigns []Campaign
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(campaignDir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var c Campaign
+		if err := json.Unmarshal(data, &c); err != nil {
+			continue
+		}
+		campaigns = append(campaigns, c)
+	}
+
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].CreatedAt.After(campaigns[j].CreatedAt)
+	})
+	return campaigns
+}
+
+// GetCampaignByID returns a campaign by ID
+func GetCampaignByID(id string) *Campaign {
+	if id == "" || id != sanitizeID(id) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(campaignDir, id+".json"))
+	if err != nil {
+		return nil
+	}
+	var c Campaign
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil
+	}
+	return &c
+}
+
+// SaveCampaign writes a campaign, assigning an ID to new ones
+func SaveCampaign(c *Campaign) error {
+	if c.ID == "" {
+		id, err := randomID()
+		if err != nil {
+			return err
+		}
+		c.ID = id
+	}
+	return jsonfile.Write(filepath.Join(campaignDir, c.ID+".json"), c, 0600)
+}
+
+// DeleteCampaign removes a campaign by ID
+func DeleteCampaign(id string) error {
+	if id == "" || id != sanitizeID(id) {
+		return os.ErrNotExist
+	}
+	return os.Remove(filepath.Join(campaignDir, id+".json"))
+}
@@ -2,9 +2,11 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Content struct {
@@ -13,20 +15,58 @@ type Content struct {
 	Data       map[string]interface{} `json:"data"`
 }
 
+// ErrInvalidContent is returned for a document outside the content
+// collections or without a usable ID
+var ErrInvalidContent = errors.New("invalid_content")
+
+// contentCollections are the collections SaveContent writes to. Posts and
+// policy areas have their own formats and are saved through the admin API.
+var contentCollections = map[string]bool{
+	"pages":    true,
+	"settings": true,
+}
+
+// IsContentCollection reports whether collection is one of the content
+// collections
+func IsContentCollection(collection string) bool {
+	return contentCollections[collection]
+}
+
+// contentID cleans a document ID such as "home" or its translation
+// "home.en", returning "" if nothing usable is left
+func contentID(id string) string {
+	base, locale, translated := strings.Cut(strings.TrimSpace(id), ".")
+	if strings.TrimSpace(base) == "" || (translated && strings.TrimSpace(locale) == "") {
+		return ""
+	}
+	base = sanitizeID(base)
+	if translated {
+		return base + "." + sanitizeID(locale)
+	}
+	return base
+}
+
+// SaveContent writes a document to its collection under ./content
 func SaveContent(content Content) error {
+	if !IsContentCollection(content.Collection) {
+		return ErrInvalidContent
+	}
+	if content.ID = contentID(content.ID); content.ID == "" {
+		return ErrInvalidContent
+	}
 	contentDir := filepath.Join("./content", content.Collection)
-	
+
 	// Create directory if it doesn't exist
 	if err := os.MkdirAll(contentDir, 0755); err != nil {
 		return err
 	}
-	
+
 	// Marshal content to JSON
 	data, err := json.MarshalIndent(content, "", "  ")
 	if err != nil {
 		return err
 	}
-	
+
 	// Write to file
 	filename := filepath.Join(contentDir, content.ID+".json")
 	return ioutil.WriteFile(filename, data, 0644)
@@ -34,16 +74,16 @@ func SaveContent(content Content) error {
 
 func GetContentByCollection(collection string) ([]Content, error) {
 	contentDir := filepath.Join("./content", collection)
-	
+
 	if _, err := os.Stat(contentDir); os.IsNotExist(err) {
 		return []Content{}, nil
 	}
-	
+
 	files, err := ioutil.ReadDir(contentDir)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	var contents []Content
 	for _, file := range files {
 		if filepath.Ext(file.Name()) == ".json" {
@@ -51,15 +91,75 @@ func GetContentByCollection(collection string) ([]Content, error) {
 			if err != nil {
 				continue
 			}
-			
+
 			var content Content
 			if err := json.Unmarshal(data, &content); err != nil {
 				continue
 			}
-			
+
+			// Documents written by TinaCMS itself are not wrapped in a
+			// Content envelope; treat the whole document as its data.
+			if content.Data == nil {
+				if err := json.Unmarshal(data, &content.Data); err != nil {
+					continue
+				}
+			}
+			if content.ID == "" {
+				content.ID = strings.TrimSuffix(file.Name(), ".json")
+			}
+			if content.Collection == "" {
+				content.Collection = collection
+			}
+
 			contents = append(contents, content)
 		}
 	}
-	
+
 	return contents, nil
-}
\ No newline at end of file
+}
+
+// GetContentByID returns a single document from a collection, or nil if it
+// does not exist
+func GetContentByID(collection, id string) *Content {
+	contents, err := GetContentByCollection(collection)
+	if err != nil {
+		return nil
+	}
+	for _, content := range contents {
+		if content.ID == id {
+			return &content
+		}
+	}
+	return nil
+}
+
+// GetLocalizedContent returns the data of a document merged with its
+// translation for locale, stored as "<id>.<locale>" in the same collection.
+// Fields missing from the translation keep their original value.
+func GetLocalizedContent(collection, id, locale string) map[string]interface{} {
+	data := map[string]interface{}{}
+	if content := GetContentByID(collection, id); content != nil && content.Data != nil {
+		data = content.Data
+	}
+	if translated := GetContentByID(collection, id+"."+locale); translated != nil && translated.Data != nil {
+		data = mergeContentData(data, translated.Data)
+	}
+	return data
+}
+
+func mergeContentData(base, overlay map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		baseObj, baseIsObj := merged[k].(map[string]interface{})
+		overlayObj, overlayIsObj := v.(map[string]interface{})
+		if baseIsObj && overlayIsObj {
+			merged[k] = mergeContentData(baseObj, overlayObj)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
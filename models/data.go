@@ -0,0 +1,15 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// randomID returns a random hex ID for records that have no natural key
+func randomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
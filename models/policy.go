@@ -0,0 +1,317 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"soma-mayel-campaign/jsonfile"
+)
+
+// PolicyArea is one of the candidate's policy positions (mærkesager)
+type PolicyArea struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Slug        string   `json:"slug"`
+	Order       int      `json:"order"`
+	Icon        string   `json:"icon"`
+	Description string   `json:"description"`
+	Points      []string `json:"points"`
+	Body        string   `json:"body"`
+
+	// Translations holds per-locale variants keyed by locale code
+	Translations map[string]PolicyAreaTranslation `json:"translations,omitempty"`
+
+	// file is the name of the file the area was read from, which for areas
+	// created in Tina need not match the ID
+	file string
+}
+
+// PolicyAreaTranslation is a translated variant of a policy area's text
+type PolicyAreaTranslation struct {
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Points      []string `json:"points,omitempty"`
+	Body        string   `json:"body,omitempty"`
+}
+
+// Localized returns a copy of the policy area with its text replaced by the
+// translation for locale, where one exists
+func (a PolicyArea) Localized(locale string) PolicyArea {
+	tr, ok := a.Translations[locale]
+	if !ok {
+		return a
+	}
+	if tr.Title != "" {
+		a.Title = tr.Title
+	}
+	if tr.Description != "" {
+		a.Description = tr.Description
+	}
+	if len(tr.Points) > 0 {
+		a.Points = tr.Points
+	}
+	if tr.Body != "" {
+		a.Body = tr.Body
+	}
+	return a
+}
+
+// LocalizePolicyAreas returns the policy areas with Localized applied to each
+func LocalizePolicyAreas(areas []PolicyArea, locale string) []PolicyArea {
+	localized := make([]PolicyArea, len(areas))
+	for i, a := range areas {
+		localized[i] = a.Localized(locale)
+	}
+	return localized
+}
+
+const policyDir = "./content/policies"
+
+// GetAllPolicyAreas returns all policy areas sorted by their order. The
+// built-in sample areas stand in until the policy directory is created by
+// the first save or delete; after that an empty directory means no areas.
+func GetAllPolicyAreas() []PolicyArea {
+	if !policyDirExists() {
+		return getSamplePolicyAreas()
+	}
+
+	files, err := ioutil.ReadDir(policyDir)
+	if err != nil {
+		return getSamplePolicyAreas()
+	}
+
+	var areas []PolicyArea
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(policyDir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var area PolicyArea
+		if err := json.Unmarshal(data, &area); err != nil {
+			continue
+		}
+		area.file = file.Name()
+		// Tina does not write an id, so the file name stands in for it
+		if area.ID == "" {
+			area.ID = strings.TrimSuffix(file.Name(), ".json")
+		}
+		areas = append(areas, area)
+	}
+
+	sort.SliceStable(areas, func(i, j int) bool {
+		if areas[i].Order != areas[j].Order {
+			return areas[i].Order < areas[j].Order
+		}
+		return areas[i].Title < areas[j].Title
+	})
+
+	return areas
+}
+
+// GetPolicyAreaBySlug returns a policy area by its slug
+func GetPolicyAreaBySlug(slug string) *PolicyArea {
+	for _, area := range GetAllPolicyAreas() {
+		if area.Slug == slug {
+			return &area
+		}
+	}
+	return nil
+}
+
+// GetPolicyAreaByID returns a policy area by its ID
+func GetPolicyAreaByID(id string) *PolicyArea {
+	for _, area := range GetAllPolicyAreas() {
+		if area.ID == id {
+			return &area
+		}
+	}
+	return nil
+}
+
+// GetPostsForPolicyArea returns the posts linked to a policy area, newest first
+func GetPostsForPolicyArea(slug string) []Post {
+	var posts []Post
+	for _, post := range GetPublishedPosts() {
+		for _, s := range post.PolicyAreas {
+			if s == slug {
+				posts = append(posts, post)
+				break
+			}
+		}
+	}
+	return posts
+}
+
+// SavePolicyArea writes or updates a policy area JSON file under
+// content/policies. A new area gets its ID from the slug, with a number
+// added if another area already has that ID.
+func SavePolicyArea(area *PolicyArea) error {
+	creating := strings.TrimSpace(area.ID) == ""
+	if creating {
+		candidate := strings.TrimSpace(area.Slug)
+		if candidate == "" {
+			candidate = area.Title
+		}
+		area.ID = candidate
+	}
+	existing := GetPolicyAreaByID(area.ID)
+	area.ID = sanitizeID(area.ID)
+	if creating {
+		area.ID = unusedPolicyID(area.ID)
+	}
+
+	// The first save replaces the built-in sample areas, so write them out
+	// as well to keep them from disappearing.
+	if !policyDirExists() {
+		if err := os.MkdirAll(policyDir, 0755); err != nil {
+			return err
+		}
+		for _, sample := range getSamplePolicyAreas() {
+			if sample.ID == area.ID {
+				continue
+			}
+			if err := writePolicyArea(&sample); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writePolicyArea(area); err != nil {
+		return err
+	}
+	// An area created in Tina under a file name that is not a clean ID
+	// moves to its new file
+	if existing != nil && existing.file != "" && existing.file != area.ID+".json" {
+		return os.Remove(filepath.Join(policyDir, existing.file))
+	}
+	return nil
+}
+
+// DeletePolicyArea removes a policy area JSON file by ID
+func DeletePolicyArea(id string) error {
+	// Deleting one of the sample areas materialises the others first
+	if !policyDirExists() {
+		if err := os.MkdirAll(policyDir, 0755); err != nil {
+			return err
+		}
+		for _, sample := range getSamplePolicyAreas() {
+			if sample.ID == id {
+				continue
+			}
+			if err := writePolicyArea(&sample); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	area := GetPolicyAreaByID(id)
+	if area == nil {
+		return nil
+	}
+	err := os.Remove(filepath.Join(policyDir, area.file))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// unusedPolicyID returns id, or id with "-2", "-3" and so on added, such
+// that no area has that ID and no file has that name
+func unusedPolicyID(id string) string {
+	candidate := id
+	for n := 2; ; n++ {
+		_, err := os.Stat(filepath.Join(policyDir, candidate+".json"))
+		if GetPolicyAreaByID(candidate) == nil && os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", id, n)
+	}
+}
+
+func writePolicyArea(area *PolicyArea) error {
+	return jsonfile.Write(filepath.Join(policyDir, sanitizeID(area.ID)+".json"), area, 0644)
+}
+
+func policyDirExists() bool {
+	info, err := os.Stat(policyDir)
+	return err == nil && info.IsDir()
+}
+
+func getSamplePolicyAreas() []PolicyArea {
+	return []PolicyArea{
+		{
+			ID:          "born-og-uddannelse",
+			Title:       "Børn og Uddannelse",
+			Slug:        "born-og-uddannelse",
+			Order:       1,
+			Icon:        "child_care",
+			Description: "Alle børn fortjener en god start på livet",
+			Points: []string{
+				"REELLE minimumsnormeringer i daginstitutioner",
+				"Mental trivsel på skoleskemaet",
+				"Kvalitetsuddannelse for alle uanset baggrund",
+			},
+		},
+		{
+			ID:          "unge-og-integration",
+			Title:       "Unge og Integration",
+			Slug:        "unge-og-integration",
+			Order:       2,
+			Icon:        "diversity",
+			Description: "Fredensborg skal være attraktiv for unge",
+			Points: []string{
+				"Større ungdomsrepræsentation i politik",
+				"Gøre kommunen attraktiv for unge at bosætte sig",
+				"Bryde barrierer for unge med forskellig baggrund",
+			},
+		},
+		{
+			ID:          "lighed-og-inklusion",
+			Title:       "Lighed og Inklusion",
+			Slug:        "lighed-og-inklusion",
+			Order:       3,
+			Icon:        "equality",
+			Description: "En kommune for alle",
+			Points: []string{
+				"Lige muligheder uanset baggrund",
+				"Bedre retssikkerhed for alle borgere",
+				"Borgerambassadør til at hjælpe med systemnavigation",
+			},
+		},
+		{
+			ID:          "baeredygtighed-og-miljo",
+			Title:       "Bæredygtighed og Miljø",
+			Slug:        "baeredygtighed-og-miljo",
+			Order:       4,
+			Icon:        "nature",
+			Description: "Grøn omstilling med ansvar",
+			Points: []string{
+				"Bevæge Fredensborg i en mere bæredygtig retning",
+				"Balance mellem økonomi og miljøansvar",
+				"Grønne initiativer i alle kommunale områder",
+			},
+		},
+		{
+			ID:          "social-retfaerdighed",
+			Title:       "Social Retfærdighed",
+			Slug:        "social-retfaerdighed",
+			Order:       5,
+			Icon:        "balance",
+			Description: "Kamp mod ulighed",
+			Points: []string{
+				"Et samfund baseret på mindre uretfærdighed",
+				"Politik der samler frem for at splitte",
+				"Fokus på de mest udsatte borgere",
+			},
+		},
+	}
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// inTempDir runs the rest of the test in an empty working directory
+func inTempDir(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func policyIDs() []string {
+	var ids []string
+	for _, a := range GetAllPolicyAreas() {
+		ids = append(ids, a.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func policyFiles(t *testing.T) []string {
+	t.Helper()
+	entries, err := os.ReadDir(policyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSavePolicyArea(t *testing.T) {
+	samples := len(getSamplePolicyAreas())
+
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T)
+		area   PolicyArea
+		wantID string
+		want   int // areas afterwards
+	}{
+		{
+			name:   "first save keeps the samples",
+			area:   PolicyArea{Title: "Kultur", Slug: "kultur"},
+			wantID: "kultur",
+			want:   samples + 1,
+		},
+		{
+			name:   "update of a sample",
+			area:   PolicyArea{ID: "social-retfaerdighed", Title: "Social retfærdighed", Slug: "social-retfaerdighed"},
+			wantID: "social-retfaerdighed",
+			want:   samples,
+		},
+		{
+			name:   "new area whose ID is taken gets a number",
+			area:   PolicyArea{Title: "Børn", Slug: "Born og Uddannelse"},
+			wantID: "born-og-uddannelse-2",
+			want:   samples + 1,
+		},
+		{
+			name: "numbers skip taken files",
+			setup: func(t *testing.T) {
+				writeTestFile(t, "content/policies/kultur.json", `{"id":"kultur","title":"Kultur","slug":"kultur"}`)
+				writeTestFile(t, "content/policies/kultur-2.json", `{"title":"Kultur 2","slug":"kultur-to"}`)
+			},
+			area:   PolicyArea{Title: "Kultur", Slug: "KULTUR"},
+			wantID: "kultur-3",
+			want:   3,
+		},
+		{
+			name: "tina file without an id is moved to a clean name",
+			setup: func(t *testing.T) {
+				writeTestFile(t, "content/policies/Miljø Og Klima.json", `{"title":"Miljø","slug":"miljo"}`)
+			},
+			area:   PolicyArea{ID: "Miljø Og Klima", Title: "Miljø og klima", Slug: "miljo"},
+			wantID: "milj-og-klima",
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inTempDir(t)
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+			area := tt.area
+			if err := SavePolicyArea(&area); err != nil {
+				t.Fatal(err)
+			}
+			if area.ID != tt.wantID {
+				t.Errorf("saved as %q, want %q", area.ID, tt.wantID)
+			}
+			if got := GetPolicyAreaByID(tt.wantID); got == nil || got.Title != tt.area.Title {
+				t.Errorf("area %q reads back as %+v", tt.wantID, got)
+			}
+			if ids := policyIDs(); len(ids) != tt.want {
+				t.Errorf("got areas %q, want %d", ids, tt.want)
+			}
+			for _, name := range policyFiles(t) {
+				if filepath.Ext(name) != ".json" {
+					t.Errorf("left %s behind", name)
+				}
+			}
+		})
+	}
+}
+
+func TestDeletePolicyArea(t *testing.T) {
+	inTempDir(t)
+	samples := getSamplePolicyAreas()
+
+	// Deleting a sample writes out the others
+	if err := DeletePolicyArea(samples[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(policyIDs()); n != len(samples)-1 {
+		t.Fatalf("%d areas after deleting a sample, want %d", n, len(samples)-1)
+	}
+
+	// A Tina file is deleted by the ID taken from its name
+	writeTestFile(t, "content/policies/Tina Area.json", `{"title":"Tina","slug":"tina"}`)
+	if err := DeletePolicyArea("Tina Area"); err != nil {
+		t.Fatal(err)
+	}
+	if GetPolicyAreaBySlug("tina") != nil {
+		t.Error("Tina area is still there")
+	}
+
+	// Deleting everything leaves no areas rather than the samples
+	for _, a := range GetAllPolicyAreas() {
+		if err := DeletePolicyArea(a.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids := policyIDs(); len(ids) != 0 {
+		t.Errorf("areas %q left after deleting all", ids)
+	}
+	if err := DeletePolicyArea("missing"); err != nil {
+		t.Errorf("deleting a missing area: %v", err)
+	}
+}
+
+func writeTestFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -21,10 +22,62 @@ type Post struct {
 	Image       string    `json:"image"`
 	Tags        []string  `json:"tags"`
 	IsFeatured  bool      `json:"is_featured"`
+	PolicyAreas []string  `json:"policy_areas,omitempty"`
+
+	// Draft posts are only visible in the admin UI
+	Draft bool `json:"draft,omitempty"`
+
+	// FacebookID and FacebookURL link the post to its Facebook counterpart
+	FacebookID  string `json:"facebook_id,omitempty"`
+	FacebookURL string `json:"facebook_url,omitempty"`
+
+	// FacebookStatus tracks publishing the post on the Facebook page
+	// (queued, published or failed) and FacebookError the last failure
+	FacebookStatus string `json:"facebook_status,omitempty"`
+	FacebookError  string `json:"facebook_error,omitempty"`
+
+	// Translations holds per-locale variants keyed by locale code. Fields
+	// left empty fall back to the Danish original.
+	Translations map[string]PostTranslation `json:"translations,omitempty"`
+}
+
+// PostTranslation is a translated variant of a post's text
+type PostTranslation struct {
+	Title   string `json:"title,omitempty"`
+	Excerpt string `json:"excerpt,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// Localized returns a copy of the post with its text replaced by the
+// translation for locale, where one exists
+func (p Post) Localized(locale string) Post {
+	tr, ok := p.Translations[locale]
+	if !ok {
+		return p
+	}
+	if tr.Title != "" {
+		p.Title = tr.Title
+	}
+	if tr.Excerpt != "" {
+		p.Excerpt = tr.Excerpt
+	}
+	if tr.Content != "" {
+		p.Content = tr.Content
+	}
+	return p
+}
+
+// LocalizePosts returns the posts with Localized applied to each
+func LocalizePosts(posts []Post, locale string) []Post {
+	localized := make([]Post, len(posts))
+	for i, p := range posts {
+		localized[i] = p.Localized(locale)
+	}
+	return localized
 }
 
 func GetLatestPosts(limit int) []Post {
-	posts := GetAllPosts()
+	posts := GetPublishedPosts()
 	if len(posts) > limit {
 		return posts[:limit]
 	}
@@ -73,8 +126,53 @@ func GetAllPosts() []Post {
 	return posts
 }
 
+// CheckPostStore reads every post in content/posts and returns how many
+// can be read, and the files that cannot with the reason, which GetAllPosts
+// skips silently. The error is only for a directory that cannot be listed.
+func CheckPostStore() (int, []string, error) {
+	files, err := ioutil.ReadDir("./content/posts")
+	if os.IsNotExist(err) {
+		// GetAllPosts shows the sample posts
+		return 0, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	n := 0
+	var bad []string
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join("./content/posts", file.Name()))
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("%s: %v", file.Name(), err))
+			continue
+		}
+		var post Post
+		if err := json.Unmarshal(data, &post); err != nil {
+			bad = append(bad, fmt.Sprintf("%s: %v", file.Name(), err))
+			continue
+		}
+		n++
+	}
+	return n, bad, nil
+}
+
+// GetPublishedPosts returns all posts except drafts, newest first
+func GetPublishedPosts() []Post {
+	var published []Post
+	for _, post := range GetAllPosts() {
+		if !post.Draft {
+			published = append(published, post)
+		}
+	}
+	return published
+}
+
+// GetPostBySlug returns a published post by its slug
 func GetPostBySlug(slug string) *Post {
-	posts := GetAllPosts()
+	posts := GetPublishedPosts()
 	for _, post := range posts {
 		if post.Slug == slug {
 			return &post
@@ -84,7 +182,7 @@ func GetPostBySlug(slug string) *Post {
 }
 
 func GetFeaturedContent() []Post {
-	posts := GetAllPosts()
+	posts := GetPublishedPosts()
 	var featured []Post
 	
 	for _, post := range posts {
@@ -124,6 +222,8 @@ func getSamplePosts() []Post {
 			Image:      "/static/images/children-education.jpg",
 			Tags:       []string{"Børn", "Uddannelse", "Politik"},
 			IsFeatured: true,
+
+			PolicyAreas: []string{"born-og-uddannelse"},
 		},
 		{
 			ID:         "3",
@@ -136,6 +236,8 @@ func getSamplePosts() []Post {
 			Image:      "/static/images/soma-story.jpg",
 			Tags:       []string{"Personligt", "Integration", "Historie"},
 			IsFeatured: false,
+
+			PolicyAreas: []string{"unge-og-integration", "lighed-og-inklusion"},
 		},
 	}
 }
\ No newline at end of file
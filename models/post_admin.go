@@ -19,6 +19,20 @@ func GetPostByID(id string) *Post {
 	return nil
 }
 
+// GetPostByFacebookID returns the post imported from or cross-posted to the
+// Facebook post with the given ID
+func GetPostByFacebookID(facebookID string) *Post {
+	if facebookID == "" {
+		return nil
+	}
+	for _, post := range GetAllPosts() {
+		if post.FacebookID == facebookID {
+			return &post
+		}
+	}
+	return nil
+}
+
 // SavePost writes or updates a post JSON file under content/posts
 func SavePost(post *Post) error {
 	if strings.TrimSpace(post.ID) == "" {
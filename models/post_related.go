@@ -0,0 +1,161 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Weights used when scoring how related two posts are. A shared tag is a
+// much stronger signal than overlapping words in the title or excerpt.
+const (
+	relatedTagWeight  = 3.0
+	relatedTextWeight = 2.0
+)
+
+// stopWords are, per locale, common words that carry no meaning on their
+// own and would otherwise make every post look similar to every other post.
+// Locales without a list use the Danish one.
+var stopWords = map[string]map[string]bool{
+	"da": wordList("og i at det en et den til er som på de med for af vi jeg har ikke der om fra men skal kan var min mit mine vores nu så også alle bliver være"),
+	"en": wordList("the and for are but not you all any can had her was one our out has have this that with they from will would there their what about which when who been were more into than them only also its over such"),
+	"fa": wordList("و در به از که این آن را با برای است بود شد هم تا یا اما نیز ما من او آنها ها های می کرد کند شود باید هر یک دیگر"),
+}
+
+// wordList builds a stop-word set from a space-separated list
+func wordList(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// GetRelatedPosts returns up to limit posts related to the given post, scored
+// by shared tags and similarity of their title, excerpt and content in the
+// given locale. Posts with no overlap at all are left out.
+func GetRelatedPosts(post *Post, locale string, limit int) []Post {
+	if post == nil || limit <= 0 {
+		return nil
+	}
+
+	stop, ok := stopWords[locale]
+	if !ok {
+		stop = stopWords["da"]
+	}
+	tags := tagSet(post.Tags)
+	words := wordSet(postText(post.Localized(locale)), stop)
+
+	type scored struct {
+		post  Post
+		score float64
+	}
+	var candidates []scored
+	for _, other := range GetPublishedPosts() {
+		if other.ID == post.ID || other.Slug == post.Slug {
+			continue
+		}
+
+		shared := 0
+		for t := range tagSet(other.Tags) {
+			if tags[t] {
+				shared++
+			}
+		}
+		similarity := jaccard(words, wordSet(postText(other.Localized(locale)), stop))
+
+		score := float64(shared)*relatedTagWeight + similarity*relatedTextWeight
+		if score <= 0 {
+			continue
+		}
+		candidates = append(candidates, scored{post: other, score: score})
+	}
+
+	// Highest score first; newest first on ties
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].post.Date.After(candidates[j].post.Date)
+	})
+
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	related := make([]Post, 0, len(candidates))
+	for _, c := range candidates {
+		related = append(related, c.post)
+	}
+	return related
+}
+
+// GetAdjacentPosts returns the chronologically previous (older) and next
+// (newer) posts relative to the given post. Either may be nil.
+func GetAdjacentPosts(post *Post) (prev *Post, next *Post) {
+	if post == nil {
+		return nil, nil
+	}
+
+	// GetPublishedPosts is sorted newest first
+	posts := GetPublishedPosts()
+	for i := range posts {
+		if posts[i].ID != post.ID || posts[i].Slug != post.Slug {
+			continue
+		}
+		if i > 0 {
+			n := posts[i-1]
+			next = &n
+		}
+		if i < len(posts)-1 {
+			p := posts[i+1]
+			prev = &p
+		}
+		break
+	}
+	return prev, next
+}
+
+func tagSet(tags []string) map[string]bool {
+	set := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" {
+			set[t] = true
+		}
+	}
+	return set
+}
+
+// postText is the text of a post compared by GetRelatedPosts
+func postText(p Post) string {
+	return p.Title + " " + p.Excerpt + " " + p.Content
+}
+
+func wordSet(text string, stopWords map[string]bool) map[string]bool {
+	set := make(map[string]bool)
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range fields {
+		// Very short words are rarely meaningful
+		if len([]rune(w)) < 3 || stopWords[w] {
+			continue
+		}
+		set[w] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	intersection := 0
+	for w := range a {
+		if b[w] {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	return float64(intersection) / float64(union)
+}
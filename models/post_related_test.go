@@ -0,0 +1,115 @@
+package models
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWordSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		text   string
+		want   []string
+	}{
+		{"danish stop words", "da", "Vi skal have flere cykelstier og bedre skoler", []string{"bedre", "cykelstier", "flere", "have", "skoler"}},
+		{"english stop words", "en", "The plan for more bike lanes and better schools", []string{"better", "bike", "lanes", "plan", "schools"}},
+		{"persian stop words", "fa", "برای مکتب‌ها و راه‌های بایسکل باید کار کرد", []string{"بایسکل", "راه", "کار", "مکتب"}},
+		{"case and punctuation", "en", "Schools! SCHOOLS, schools.", []string{"schools"}},
+		{"short words and digits", "da", "Ny p-plads i 2026", []string{"2026", "plads"}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for w := range wordSet(tt.text, stopWords[tt.locale]) {
+			got = append(got, w)
+		}
+		sort.Strings(got)
+		sort.Strings(tt.want)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: wordSet = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	set := func(words ...string) map[string]bool {
+		s := map[string]bool{}
+		for _, w := range words {
+			s[w] = true
+		}
+		return s
+	}
+	tests := []struct {
+		a, b map[string]bool
+		want float64
+	}{
+		{set("a", "b"), set("a", "b"), 1},
+		{set("a", "b"), set("c"), 0},
+		{set("a", "b", "c"), set("b", "c", "d"), 0.5},
+		{set(), set("a"), 0},
+	}
+	for _, tt := range tests {
+		if got := jaccard(tt.a, tt.b); got != tt.want {
+			t.Errorf("jaccard(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGetRelatedPosts(t *testing.T) {
+	inTempDir(t)
+
+	day := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	posts := []Post{
+		{ID: "cykler", Title: "Flere cykelstier", Excerpt: "Sikre cykelstier til skolerne", Tags: []string{"Trafik"}, Date: day,
+			Translations: map[string]PostTranslation{"en": {Title: "More cycle lanes", Excerpt: "Safe cycle lanes for schools"}}},
+		{ID: "veje", Title: "Nye veje", Excerpt: "Asfalt og huller", Tags: []string{"trafik"}, Date: day.Add(-24 * time.Hour),
+			Translations: map[string]PostTranslation{"en": {Title: "New roads", Excerpt: "Tarmac and potholes"}}},
+		{ID: "skoler", Title: "Bedre skoler", Excerpt: "Cykelstier og skolerne", Date: day.Add(-48 * time.Hour),
+			Translations: map[string]PostTranslation{"en": {Title: "Better schools", Excerpt: "Safe cycle lanes and schools"}}},
+		{ID: "skolemad", Title: "Skolemad", Excerpt: "Cykelstier og sund mad i skolerne", Date: day.Add(-72 * time.Hour)},
+		{ID: "kladde", Title: "Kladde om cykelstier", Tags: []string{"trafik"}, Date: day, Draft: true},
+		{ID: "fest", Title: "Sommerfest", Excerpt: "Kom og vær med", Date: day},
+	}
+	for i := range posts {
+		posts[i].Slug = posts[i].ID
+		if err := SavePost(&posts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		post   string
+		locale string
+		limit  int
+		want   []string
+	}{
+		// A shared tag outweighs shared words; drafts and unrelated posts
+		// are left out
+		{"danish", "cykler", "da", 5, []string{"veje", "skoler", "skolemad"}},
+		{"limit", "cykler", "da", 2, []string{"veje", "skoler"}},
+		// Compared in English, the school post shares more words, while the
+		// untranslated post no longer shares any
+		{"english", "cykler", "en", 5, []string{"veje", "skoler"}},
+		{"unknown locale uses danish", "cykler", "de", 5, []string{"veje", "skoler", "skolemad"}},
+		{"nothing related", "fest", "da", 5, []string{}},
+		{"no limit", "cykler", "da", 0, []string{}},
+	}
+	for _, tt := range tests {
+		var post *Post
+		for i := range posts {
+			if posts[i].ID == tt.post {
+				post = &posts[i]
+			}
+		}
+		got := []string{}
+		for _, p := range GetRelatedPosts(post, tt.locale, tt.limit) {
+			got = append(got, p.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: related posts = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"soma-mayel-campaign/jsonfile"
+)
+
+// QuarantinedSubmission is a public form submission that was held back as
+// likely spam, kept so staff can review it and let it through
+type QuarantinedSubmission struct {
+	ID     string            `json:"id"`
+	Form   string            `json:"form"`
+	Reason string            `json:"reason"`
+	Score  int               `json:"score,omitempty"`
+	Fields map[string]string `json:"fields"`
+	Email  string            `json:"email,omitempty"`
+	IP     string            `json:"ip"`
+	// UserAgent and BaseURL describe the original request, for review and
+	// for links in e-mails sent when the submission is released
+	UserAgent string    `json:"user_agent,omitempty"`
+	BaseURL   string    `json:"base_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const quarantineDir = "./data/quarantine"
+
+// GetAllQuarantined returns all quarantined submissions, newest first
+func GetAllQuarantined() []QuarantinedSubmission {
+	files, err := ioutil.ReadDir(quarantineDir)
+	if err != nil {
+		return nil
+	}
+
+	var submissions []QuarantinedSubmission
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(quarantineDir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var q QuarantinedSubmission
+		if err := json.Unmarshal(data, &q); err != nil {
+			continue
+		}
+		submissions = append(submissions, q)
+	}
+
+	sort.Slice(submissions, func(i, j int) bool {
+		return submissions[i].CreatedAt.After(submissions[j].CreatedAt)
+	})
+	return submissions
+}
+
+// GetQuarantinedByID returns a quarantined submission by ID
+func GetQuarantinedByID(id string) *QuarantinedSubmission {
+	if id == "" || id != sanitizeID(id) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(quarantineDir, id+".json"))
+	if err != nil {
+		return nil
+	}
+	var q QuarantinedSubmission
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil
+	}
+	return &q
+}
+
+// SaveQuarantined writes a quarantined submission, assigning an ID to new
+// ones
+func SaveQuarantined(q *QuarantinedSubmission) error {
+	if q.ID == "" {
+		id, err := randomID()
+		if err != nil {
+			return err
+		}
+		q.ID = id
+	}
+	return jsonfile.Write(filepath.Join(quarantineDir, q.ID+".json"), q, 0600)
+}
+
+// DeleteQuarantined removes a quarantined submission by ID
+func DeleteQuarantined(id string) error {
+	if id == "" || id != sanitizeID(id) {
+		return os.ErrNotExist
+	}
+	return os.Remove(filepath.Join(quarantineDir, id+".json"))
+}
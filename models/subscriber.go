@@ -0,0 +1,136 @@
+package models
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"soma-mayel-campaign/jsonfile"
+)
+
+// Subscriber statuses. A subscriber only receives newsletters once they have
+// confirmed their address.
+const (
+	SubscriberPending      = "pending"
+	SubscriberConfirmed    = "confirmed"
+	SubscriberUnsubscribed = "unsubscribed"
+)
+
+// Subscriber is a newsletter recipient. Subscribers are personal data, so
+// they live under ./data rather than the publicly served ./content.
+type Subscriber struct {
+	ID     string `json:"id"`
+	Email  string `json:"email"`
+	Name   string `json:"name,omitempty"`
+	Locale string `json:"locale"`
+	Status string `json:"status"`
+
+	// Source is the page the subscriber signed up from
+	Source string `json:"source,omitempty"`
+	// ConsentText is the wording the subscriber agreed to
+	ConsentText string `json:"consent_text,omitempty"`
+
+	CreatedAt      time.Time  `json:"created_at"`
+	ConfirmSentAt  *time.Time `json:"confirm_sent_at,omitempty"`
+	ConsentAt      *time.Time `json:"consent_at,omitempty"`
+	UnsubscribedAt *time.Time `json:"unsubscribed_at,omitempty"`
+}
+
+const subscriberDir = "./data/subscribers"
+
+// NormalizeEmail lower-cases and trims an address so each person is stored once
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// GetAllSubscribers returns all subscribers, newest first
+func GetAllSubscribers() []Subscriber {
+	files, err := ioutil.ReadDir(subscriberDir)
+	if err != nil {
+		return nil
+	}
+
+	var subscribers []Subscriber
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(subscriberDir, file.Name()))
+		if err != nil {
+			continue
+		}
+		var s Subscriber
+		if err := json.Unmarshal(data, &s); err != nil {
+			continue
+		}
+		subscribers = append(subscribers, s)
+	}
+
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].CreatedAt.After(subscribers[j].CreatedAt)
+	})
+	return subscribers
+}
+
+// GetConfirmedSubscribers returns the subscribers who receive newsletters
+func GetConfirmedSubscribers() []Subscriber {
+	var confirmed []Subscriber
+	for _, s := range GetAllSubscribers() {
+		if s.Status == SubscriberConfirmed {
+			confirmed = append(confirmed, s)
+		}
+	}
+	return confirmed
+}
+
+// GetSubscriberByID returns a subscriber by ID
+func GetSubscriberByID(id string) *Subscriber {
+	if id == "" || id != sanitizeID(id) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(subscriberDir, id+".json"))
+	if err != nil {
+		return nil
+	}
+	var s Subscriber
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil
+	}
+	return &s
+}
+
+// GetSubscriberByEmail returns the subscriber with the given address
+func GetSubscriberByEmail(email string) *Subscriber {
+	email = NormalizeEmail(email)
+	for _, s := range GetAllSubscribers() {
+		if s.Email == email {
+			return &s
+		}
+	}
+	return nil
+}
+
+// SaveSubscriber writes a subscriber, assigning an ID to new ones
+func SaveSubscriber(s *Subscriber) error {
+	if s.ID == "" {
+		id, err := randomID()
+		if err != nil {
+			return err
+		}
+		s.ID = id
+	}
+	s.Email = NormalizeEmail(s.Email)
+	return jsonfile.Write(filepath.Join(subscriberDir, s.ID+".json"), s, 0600)
+}
+
+// DeleteSubscriber removes a subscriber by ID
+func DeleteSubscriber(id string) error {
+	if id == "" || id != sanitizeID(id) {
+		return os.ErrNotExist
+	}
+	return os.Remove(filepath.Join(subscriberDir, id+".json"))
+}
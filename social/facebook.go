@@ -0,0 +1,38 @@
+package social
+
+import (
+	"context"
+
+	"soma-mayel-campaign/facebook"
+)
+
+// Facebook provides the posts of a Facebook page
+type Facebook struct {
+	// Client returns the Graph client to use. It is called on every fetch
+	// so a token changed in the admin UI takes effect straight away.
+	Client func() facebook.Client
+	PageID string
+}
+
+// Name implements Provider
+func (f *Facebook) Name() string { return "facebook" }
+
+// Fetch implements Provider
+func (f *Facebook) Fetch(ctx context.Context, limit int) ([]Item, error) {
+	posts, err := f.Client().PagePosts(ctx, f.PageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(posts))
+	for _, p := range posts {
+		items = append(items, Item{
+			ID:           p.ID,
+			Source:       f.Name(),
+			Message:      p.Message,
+			CreatedTime:  p.CreatedTime,
+			PermalinkURL: p.PermalinkURL,
+			Image:        p.FullPicture,
+		})
+	}
+	return items, nil
+}
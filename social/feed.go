@@ -0,0 +1,301 @@
+package social
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// maxFeedSize bounds how much of a feed is read
+const maxFeedSize = 5 << 20
+
+// Feed provides the entries of an RSS 2.0 or Atom feed, such as the local
+// party branch's news
+type Feed struct {
+	SourceName string
+	URL        string
+	HTTP       *http.Client
+}
+
+// NewFeed creates a feed provider reporting its items as source name
+func NewFeed(name, url string) *Feed {
+	return &Feed{
+		SourceName: name,
+		URL:        url,
+		HTTP:       &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Name implements Provider
+func (f *Feed) Name() string { return f.SourceName }
+
+// Fetch implements Provider
+func (f *Feed) Fetch(ctx context.Context, limit int) ([]Item, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.8")
+	req.Header.Set("User-Agent", "soma-mayel-campaign feed reader")
+
+	client := f.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("feed status %d", res.StatusCode)
+	}
+
+	items, err := parseFeed(io.LimitReader(res.Body, maxFeedSize), f.Name(), res.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	items = Merge(items)
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+type feedDocument struct {
+	XMLName xml.Name
+	// RSS 2.0
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	// Atom
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	GUID        string `xml:"guid"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Enclosure   struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	Media []struct {
+		URL    string `xml:"url,attr"`
+		Medium string `xml:"medium,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnail struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type atomEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// feedCharsets are the encodings other than UTF-8 that feeds are read in.
+// Danish feeds that are not UTF-8 are almost always Latin-1 or its Windows
+// variant.
+var feedCharsets = map[string]encoding.Encoding{
+	"us-ascii":     encoding.Nop,
+	"ascii":        encoding.Nop,
+	"iso-8859-1":   charmap.ISO8859_1,
+	"iso8859-1":    charmap.ISO8859_1,
+	"latin1":       charmap.ISO8859_1,
+	"l1":           charmap.ISO8859_1,
+	"iso-8859-15":  charmap.ISO8859_15,
+	"latin-9":      charmap.ISO8859_15,
+	"windows-1252": charmap.Windows1252,
+	"cp1252":       charmap.Windows1252,
+}
+
+// feedCharsetReader decodes a feed declared in one of feedCharsets and
+// rejects any other encoding rather than showing garbled text
+func feedCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, ok := feedCharsets[strings.ToLower(strings.TrimSpace(charset))]
+	if !ok {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// parseFeed reads an RSS 2.0 or Atom document. Relative links and images
+// are resolved against base, the feed's address.
+func parseFeed(r io.Reader, source string, base *url.URL) ([]Item, error) {
+	var doc feedDocument
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.CharsetReader = feedCharsetReader
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse feed: %w", err)
+	}
+
+	var items []Item
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		for _, it := range doc.Channel.Items {
+			body := it.Description
+			if body == "" {
+				body = it.Encoded
+			}
+			id := it.GUID
+			if id == "" {
+				id = it.Link
+			}
+			date := it.PubDate
+			if date == "" {
+				date = it.Date
+			}
+			items = append(items, Item{
+				ID:           id,
+				Source:       source,
+				Title:        plainText(it.Title),
+				Message:      plainText(body),
+				CreatedTime:  parseFeedTime(date),
+				PermalinkURL: webURL(base, it.Link),
+				Image:        webURL(base, it.image(body)),
+			})
+		}
+	case "feed":
+		for _, e := range doc.Entries {
+			body := e.Summary
+			if body == "" {
+				body = e.Content
+			}
+			date := e.Published
+			if date == "" {
+				date = e.Updated
+			}
+			items = append(items, Item{
+				ID:           e.ID,
+				Source:       source,
+				Title:        plainText(e.Title),
+				Message:      plainText(body),
+				CreatedTime:  parseFeedTime(date),
+				PermalinkURL: webURL(base, e.link()),
+				Image:        webURL(base, firstImage(e.Content+e.Summary)),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("parse feed: unsupported root element <%s>", doc.XMLName.Local)
+	}
+	return items, nil
+}
+
+func (it rssItem) image(body string) string {
+	if strings.HasPrefix(it.Enclosure.Type, "image/") {
+		return it.Enclosure.URL
+	}
+	for _, m := range it.Media {
+		if m.Medium == "image" || strings.HasPrefix(m.Type, "image/") {
+			return m.URL
+		}
+	}
+	if it.Thumbnail.URL != "" {
+		return it.Thumbnail.URL
+	}
+	return firstImage(body + it.Encoded)
+}
+
+func (e atomEntry) link() string {
+	for _, l := range e.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(e.Links) > 0 {
+		return e.Links[0].Href
+	}
+	return ""
+}
+
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02",
+}
+
+// parseFeedTime parses the date formats seen in the wild in RSS and Atom
+// feeds and returns the zero time if none match
+func parseFeedTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var (
+	tagPattern   = regexp.MustCompile(`<[^>]*>`)
+	imgPattern   = regexp.MustCompile(`(?i)<img[^>]+src=["']([^"']+)["']`)
+	spacePattern = regexp.MustCompile(`\s+`)
+)
+
+// plainText strips markup from feed HTML
+func plainText(s string) string {
+	s = tagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+}
+
+// webURL resolves ref against base and returns it if it is an http or https
+// address, and "" otherwise, so that feeds cannot put javascript: or data:
+// links on the site
+func webURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// firstImage returns the source of the first <img> in s
+func firstImage(s string) string {
+	if m := imgPattern.FindStringSubmatch(s); m != nil {
+		return html.UnescapeString(m[1])
+	}
+	return ""
+}
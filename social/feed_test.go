@@ -0,0 +1,185 @@
+package social
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+  <channel>
+    <title>Radikale Fredensborg</title>
+    <item>
+      <guid>news-1</guid>
+      <title>Ny cykelsti &amp; bedre busser</title>
+      <link>https://example.org/news/1</link>
+      <description>&lt;p&gt;Vi vil have  flere &lt;b&gt;cykelstier&lt;/b&gt;.&lt;/p&gt;</description>
+      <pubDate>Mon, 02 Sep 2024 10:00:00 +0200</pubDate>
+      <media:content url="https://example.org/img/1.jpg" medium="image"/>
+    </item>
+    <item>
+      <title>Relativt link</title>
+      <link>/news/2</link>
+      <description>&lt;img src="/img/2.jpg"&gt; Tekst</description>
+      <pubDate>Sun, 01 Sep 2024 10:00:00 +0200</pubDate>
+    </item>
+    <item>
+      <guid>news-3</guid>
+      <title>Farlige links</title>
+      <link>javascript:alert(1)</link>
+      <description>Tekst</description>
+      <enclosure url="data:image/png;base64,AAAA" type="image/png"/>
+    </item>
+  </channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Nyheder</title>
+  <entry>
+    <id>urn:entry:1</id>
+    <title>Valgmøde i Humlebæk</title>
+    <link rel="self" href="https://example.org/feed/1.xml"/>
+    <link rel="alternate" type="text/html" href="https://example.org/valgmoede"/>
+    <summary>Kom og mød Soma</summary>
+    <content type="html">&lt;img src="https://example.org/img/moede.jpg"&gt;</content>
+    <published>2024-09-03T18:30:00+02:00</published>
+  </entry>
+</feed>`
+
+func TestParseFeed(t *testing.T) {
+	base, _ := url.Parse("https://example.org/feed.xml")
+
+	tests := []struct {
+		name string
+		feed string
+		want []Item
+	}{
+		{
+			name: "rss 2.0",
+			feed: rssFeed,
+			want: []Item{
+				{
+					ID:           "news-1",
+					Title:        "Ny cykelsti & bedre busser",
+					Message:      "Vi vil have flere cykelstier .",
+					CreatedTime:  time.Date(2024, 9, 2, 8, 0, 0, 0, time.UTC),
+					PermalinkURL: "https://example.org/news/1",
+					Image:        "https://example.org/img/1.jpg",
+				},
+				{
+					ID:           "/news/2",
+					Title:        "Relativt link",
+					Message:      "Tekst",
+					CreatedTime:  time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC),
+					PermalinkURL: "https://example.org/news/2",
+					Image:        "https://example.org/img/2.jpg",
+				},
+				{ID: "news-3", Title: "Farlige links", Message: "Tekst"},
+			},
+		},
+		{
+			name: "atom",
+			feed: atomFeed,
+			want: []Item{{
+				ID:           "urn:entry:1",
+				Title:        "Valgmøde i Humlebæk",
+				Message:      "Kom og mød Soma",
+				CreatedTime:  time.Date(2024, 9, 3, 16, 30, 0, 0, time.UTC),
+				PermalinkURL: "https://example.org/valgmoede",
+				Image:        "https://example.org/img/moede.jpg",
+			}},
+		},
+		{
+			name: "latin-1",
+			feed: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+				"<rss version=\"2.0\"><channel><item><guid>l1</guid>" +
+				"<title>K\xf8benhavn og \xc6r\xf8</title><description>Bl\xe5 bog</description>" +
+				"</item></channel></rss>",
+			want: []Item{{ID: "l1", Title: "København og Ærø", Message: "Blå bog"}},
+		},
+		{
+			name: "windows-1252",
+			feed: "<?xml version=\"1.0\" encoding=\"windows-1252\"?>\n" +
+				"<rss version=\"2.0\"><channel><item><guid>w1</guid>" +
+				"<title>\x93Gr\xf8n\x94 omstilling \x96 nu</title>" +
+				"</item></channel></rss>",
+			want: []Item{{ID: "w1", Title: "“Grøn” omstilling – nu"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := parseFeed(strings.NewReader(tt.feed), "news", base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != len(tt.want) {
+				t.Fatalf("got %d items, want %d: %+v", len(items), len(tt.want), items)
+			}
+			for i, want := range tt.want {
+				want.Source = "news"
+				got := items[i]
+				if !got.CreatedTime.Equal(want.CreatedTime) {
+					t.Errorf("item %d: created %v, want %v", i, got.CreatedTime, want.CreatedTime)
+				}
+				got.CreatedTime, want.CreatedTime = time.Time{}, time.Time{}
+				if got != want {
+					t.Errorf("item %d:\n got %+v\nwant %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		feed string
+	}{
+		{name: "unknown charset", feed: `<?xml version="1.0" encoding="koi8-r"?><rss version="2.0"><channel></channel></rss>`},
+		{name: "not a feed", feed: `<html><body>Not found</body></html>`},
+		{name: "not xml", feed: `{"items": []}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if items, err := parseFeed(strings.NewReader(tt.feed), "news", nil); err == nil {
+				t.Errorf("parsed %+v, want an error", items)
+			}
+		})
+	}
+}
+
+func TestFeedFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/news/feed.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(rssFeed))
+	}))
+	defer srv.Close()
+
+	items, err := NewFeed("news", srv.URL+"/news/feed.xml").Fetch(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	if items[0].ID != "news-1" {
+		t.Errorf("first item %q, want the newest, news-1", items[0].ID)
+	}
+	if want := srv.URL + "/news/2"; items[1].PermalinkURL != want {
+		t.Errorf("relative link resolved to %q, want %q", items[1].PermalinkURL, want)
+	}
+
+	if _, err := NewFeed("news", srv.URL+"/missing.xml").Fetch(context.Background(), 2); err == nil {
+		t.Error("fetching a missing feed succeeded")
+	}
+}
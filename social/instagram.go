@@ -0,0 +1,41 @@
+package social
+
+import (
+	"context"
+
+	"soma-mayel-campaign/facebook"
+)
+
+// Instagram provides the media of an Instagram professional account through
+// the Instagram Graph API
+type Instagram struct {
+	Client func() facebook.InstagramClient
+	UserID string
+}
+
+// Name implements Provider
+func (i *Instagram) Name() string { return "instagram" }
+
+// Fetch implements Provider
+func (i *Instagram) Fetch(ctx context.Context, limit int) ([]Item, error) {
+	media, err := i.Client().UserMedia(ctx, i.UserID, limit)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(media))
+	for _, m := range media {
+		image := m.MediaURL
+		if m.MediaType == "VIDEO" {
+			image = m.ThumbnailURL
+		}
+		items = append(items, Item{
+			ID:           m.ID,
+			Source:       i.Name(),
+			Message:      m.Caption,
+			CreatedTime:  m.Timestamp,
+			PermalinkURL: m.Permalink,
+			Image:        image,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,40 @@
+// Package social fetches posts from the campaign's social media accounts and
+// news feeds in a common shape.
+package social
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Item is a post from any source
+type Item struct {
+	ID           string    `json:"id"`
+	Source       string    `json:"source"`
+	Title        string    `json:"title,omitempty"`
+	Message      string    `json:"message,omitempty"`
+	CreatedTime  time.Time `json:"created_time"`
+	PermalinkURL string    `json:"permalink_url,omitempty"`
+	Image        string    `json:"image,omitempty"`
+}
+
+// Provider fetches the newest items from one source
+type Provider interface {
+	// Name identifies the source in responses and filters
+	Name() string
+	// Fetch returns up to limit items, newest first
+	Fetch(ctx context.Context, limit int) ([]Item, error)
+}
+
+// Merge combines item lists into one, newest first
+func Merge(lists ...[]Item) []Item {
+	var merged []Item
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].CreatedTime.After(merged[j].CreatedTime)
+	})
+	return merged
+}
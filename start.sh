@@ -31,7 +31,7 @@ echo "✓ Dependencies installed"
 
 # Build the application
 echo "Building application..."
-go build -o bin/soma-campaign main.go
+go build -o bin/soma-campaign .
 if [ $? -eq 0 ]; then
     echo "✓ Build successful"
 else
@@ -145,10 +145,11 @@ document.addEventListener('DOMContentLoaded', function() {
 				fbContainer.classList.add('has-posts');
 				const fragment = document.createDocumentFragment();
 
+				const lang = document.documentElement.lang || 'da';
 				const formatDate = (iso) => {
 					try {
 						const d = new Date(iso);
-						return d.toLocaleDateString('da-DK', { day: 'numeric', month: 'long', year: 'numeric' });
+						return d.toLocaleDateString(lang, { day: 'numeric', month: 'long', year: 'numeric' });
 					} catch (_) {
 						return '';
 					}
@@ -163,12 +164,12 @@ document.addEventListener('DOMContentLoaded', function() {
 					const card = document.createElement('article');
 					card.className = 'fb-card';
 
-					if (post.full_picture) {
+					if (post.image) {
 						const imgWrap = document.createElement('div');
 						imgWrap.className = 'fb-card-image';
 						const img = document.createElement('img');
-						img.src = post.full_picture;
-						img.alt = 'Facebook opslag';
+						img.src = post.image;
+						img.alt = fbContainer.dataset.imageAlt || 'Facebook opslag';
 						imgWrap.appendChild(img);
 						card.appendChild(imgWrap);
 					}
@@ -194,7 +195,7 @@ document.addEventListener('DOMContentLoaded', function() {
 						a.href = post.permalink_url;
 						a.target = '_blank';
 						a.rel = 'noopener noreferrer';
-						a.textContent = 'Læs på Facebook →';
+						a.textContent = fbContainer.dataset.readLabel || 'Læs på Facebook →';
 						content.appendChild(a);
 					}
 
@@ -209,6 +210,47 @@ document.addEventListener('DOMContentLoaded', function() {
 	}
 });
 
+// Consent banner. Optional trackers, currently Google Analytics when
+// GA_TRACKING_ID is set, load only after the visitor accepts them. The choice
+// is kept in localStorage, not in a cookie.
+document.addEventListener('DOMContentLoaded', function() {
+    const banner = document.getElementById('consentBanner');
+    if (!banner) return;
+    const consentKey = 'tracking-consent';
+
+    function loadTrackers() {
+        const gaId = banner.dataset.gaId;
+        if (!gaId || window.gtag) return;
+        const script = document.createElement('script');
+        script.async = true;
+        script.src = 'https://www.googletagmanager.com/gtag/js?id=' + encodeURIComponent(gaId);
+        document.head.appendChild(script);
+        window.dataLayer = window.dataLayer || [];
+        window.gtag = function() { window.dataLayer.push(arguments); };
+        window.gtag('js', new Date());
+        window.gtag('config', gaId, { anonymize_ip: true });
+    }
+
+    let choice = null;
+    try { choice = localStorage.getItem(consentKey); } catch (e) { /* storage blocked */ }
+    if (choice === 'granted') loadTrackers();
+    else if (choice !== 'denied') banner.hidden = false;
+
+    banner.querySelectorAll('[data-consent]').forEach(btn => {
+        btn.addEventListener('click', () => {
+            const value = btn.dataset.consent;
+            try { localStorage.setItem(consentKey, value); } catch (e) { /* storage blocked */ }
+            banner.hidden = true;
+            if (value === 'granted') loadTrackers();
+            // Trackers already loaded stop on the next page view
+        });
+    });
+
+    document.querySelectorAll('[data-consent-open]').forEach(btn => {
+        btn.addEventListener('click', () => { banner.hidden = false; });
+    });
+});
+
 // Add fadeInUp animation
 const style = document.createElement('style');
 style.textContent = `
@@ -256,6 +298,14 @@ if (typeof TinaCMS !== 'undefined') {
             },
         ],
         onSubmit: async (values) => {
+            // The hero is part of the site settings document; keep the rest
+            // of it and replace only the hero fields
+            const response = await fetch('/api/tina/content/settings');
+            const documents = response.ok ? await response.json() : [];
+            const site = documents.find((doc) => doc.id === 'site');
+            const data = Object.assign({}, site ? site.data : {});
+            data.hero = Object.assign({}, data.hero, values);
+
             // Send to backend
             await fetch('/api/tina/content', {
                 method: 'POST',
@@ -263,9 +313,9 @@ if (typeof TinaCMS !== 'undefined') {
                     'Content-Type': 'application/json',
                 },
                 body: JSON.stringify({
-                    collection: 'hero',
-                    id: 'home',
-                    data: values,
+                    collection: 'settings',
+                    id: 'site',
+                    data: data,
                 }),
             });
         },
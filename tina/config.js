@@ -74,6 +74,12 @@ export default defineConfig({
             name: 'isFeatured',
             label: 'Featured Post',
           },
+          {
+            type: 'string',
+            name: 'policy_areas',
+            label: 'Policy Areas (slugs)',
+            list: true,
+          },
           {
             type: 'rich-text',
             name: 'content',
@@ -101,12 +107,109 @@ export default defineConfig({
             label: 'Content',
             isBody: true,
           },
+          {
+            type: 'object',
+            name: 'timeline',
+            label: 'Timeline (Om Soma)',
+            list: true,
+            ui: {
+              itemProps: (item) => ({ label: `${item?.year} ${item?.title}` }),
+            },
+            fields: [
+              {
+                type: 'string',
+                name: 'year',
+                label: 'Year',
+              },
+              {
+                type: 'string',
+                name: 'title',
+                label: 'Title',
+              },
+              {
+                type: 'string',
+                name: 'description',
+                label: 'Description',
+              },
+            ],
+          },
+          {
+            type: 'object',
+            name: 'roles',
+            label: 'Roles (Om Soma)',
+            list: true,
+            ui: {
+              itemProps: (item) => ({ label: item?.title }),
+            },
+            fields: [
+              {
+                type: 'string',
+                name: 'title',
+                label: 'Title',
+              },
+              {
+                type: 'string',
+                name: 'icon',
+                label: 'Icon',
+              },
+            ],
+          },
+        ],
+      },
+      {
+        name: 'policy',
+        label: 'Policy Areas',
+        path: 'content/policies',
+        format: 'json',
+        fields: [
+          {
+            type: 'string',
+            name: 'title',
+            label: 'Title',
+            isTitle: true,
+            required: true,
+          },
+          {
+            type: 'string',
+            name: 'slug',
+            label: 'Slug',
+            required: true,
+          },
+          {
+            type: 'number',
+            name: 'order',
+            label: 'Order',
+          },
+          {
+            type: 'string',
+            name: 'icon',
+            label: 'Icon',
+          },
+          {
+            type: 'string',
+            name: 'description',
+            label: 'Description',
+          },
+          {
+            type: 'string',
+            name: 'points',
+            label: 'Points',
+            list: true,
+          },
+          {
+            type: 'string',
+            name: 'body',
+            label: 'Explanation',
+            ui: {
+              component: 'textarea',
+            },
+          },
         ],
       },
       {
         name: 'settings',
         label: 'Site Settings',
-        path: 'content',
+        path: 'content/settings',
         format: 'json',
         ui: {
           allowedActions: {